@@ -0,0 +1,50 @@
+// Command gomicro-audit verifies the audit files written by audit.FileSink, see package audit.
+//
+//	gomicro-audit <file>...
+//
+// It checks the sequence of the records, and the hash chain if the file is chained,
+// prints the number of the valid records of every file, and exits 1 if any file is broken.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gomicro/rpc/audit"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s <file>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	broken := false
+	for _, path := range flag.Args() {
+		n, err := verify(path)
+		if err != nil {
+			broken = true
+			fmt.Printf("%s: BROKEN after %d valid records: %v\n", path, n, err)
+			continue
+		}
+		fmt.Printf("%s: OK, %d records\n", path, n)
+	}
+	if broken {
+		os.Exit(1)
+	}
+}
+
+func verify(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return audit.Verify(file)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// caller lists, describes & invokes the methods by server reflection
+type caller struct {
+	ctx    context.Context
+	conn   *grpc.ClientConn
+	client *grpcreflect.Client
+}
+
+func newCaller(ctx context.Context, conn *grpc.ClientConn) *caller {
+	return &caller{
+		ctx:    ctx,
+		conn:   conn,
+		client: grpcreflect.NewClient(ctx, rpb.NewServerReflectionClient(conn)),
+	}
+}
+
+func (c *caller) close() {
+	c.client.Reset()
+}
+
+// list the services, or the methods of the services
+func (c *caller) list(services []string) error {
+	if len(services) == 0 {
+		all, err := c.client.ListServices()
+		if err != nil {
+			return fmt.Errorf("list services error: %v", err)
+		}
+		sort.Strings(all)
+		for _, service := range all {
+			fmt.Println(service)
+		}
+		return nil
+	}
+
+	for _, name := range services {
+		service, err := c.client.ResolveService(name)
+		if err != nil {
+			return fmt.Errorf("resolve service %s error: %v", name, err)
+		}
+		for _, m := range service.GetMethods() {
+			fmt.Printf("/%s/%s\n", service.GetFullyQualifiedName(), m.GetName())
+		}
+	}
+	return nil
+}
+
+// describe prints the proto source of the service, method or message
+func (c *caller) describe(symbol string) error {
+	symbol = strings.Trim(strings.Replace(symbol, "/", ".", -1), ".")
+	file, err := c.client.FileContainingSymbol(symbol)
+	if err != nil {
+		return fmt.Errorf("resolve %s error: %v", symbol, err)
+	}
+	d := file.FindSymbol(symbol)
+	if d == nil {
+		return fmt.Errorf("symbol %s not found in %s", symbol, file.GetName())
+	}
+
+	printer := &protoprint.Printer{}
+	toPrint := []desc.Descriptor{d}
+	if m, ok := d.(*desc.MethodDescriptor); ok {
+		toPrint = append(toPrint, m.GetInputType(), m.GetOutputType())
+	}
+	for _, d := range toPrint {
+		source, err := printer.PrintProtoToString(d)
+		if err != nil {
+			return fmt.Errorf("print %s error: %v", d.GetFullyQualifiedName(), err)
+		}
+		fmt.Printf("%s:\n%s\n", d.GetFullyQualifiedName(), source)
+	}
+	return nil
+}
+
+// invoke the method with the JSON request, "-" reads it from stdin
+func (c *caller) invoke(fullMethod, request string) error {
+	name := strings.Trim(fullMethod, "/")
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return fmt.Errorf("invalid method %s, want package.Service/Method", fullMethod)
+	}
+
+	service, err := c.client.ResolveService(name[:i])
+	if err != nil {
+		return fmt.Errorf("resolve service %s error: %v", name[:i], err)
+	}
+	method := service.FindMethodByName(name[i+1:])
+	if method == nil {
+		return fmt.Errorf("method %s not found in %s", name[i+1:], name[:i])
+	}
+	if method.IsClientStreaming() {
+		return fmt.Errorf("client streaming method %s is not supported", fullMethod)
+	}
+
+	if request == "-" {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read request error: %v", err)
+		}
+		request = string(b)
+	}
+	req := dynamic.NewMessage(method.GetInputType())
+	if strings.TrimSpace(request) != "" {
+		if err := req.UnmarshalJSON([]byte(request)); err != nil {
+			return fmt.Errorf("invalid request: %v", err)
+		}
+	}
+
+	stub := grpcdynamic.NewStub(c.conn)
+	if !method.IsServerStreaming() {
+		resp, err := stub.InvokeRpc(c.ctx, method, req)
+		if err != nil {
+			return statusError(err)
+		}
+		printMessage(resp)
+		fmt.Fprintln(os.Stderr, "status: OK")
+		return nil
+	}
+
+	stream, err := stub.InvokeRpcServerStream(c.ctx, method, req)
+	if err != nil {
+		return statusError(err)
+	}
+	for {
+		resp, err := stream.RecvMsg()
+		if err == io.EOF {
+			fmt.Fprintln(os.Stderr, "status: OK")
+			return nil
+		}
+		if err != nil {
+			return statusError(err)
+		}
+		printMessage(resp)
+	}
+}
+
+func printMessage(m proto.Message) {
+	if dm, ok := m.(*dynamic.Message); ok {
+		if b, err := dm.MarshalJSONIndent(); err == nil {
+			fmt.Println(string(b))
+			return
+		}
+	}
+	fmt.Println(proto.MarshalTextString(m))
+}
+
+// statusError formats the status with its details
+func statusError(err error) error {
+	st, _ := status.FromError(err)
+	details, jsErr := (&jsonpb.Marshaler{Indent: "  "}).MarshalToString(st.Proto())
+	if jsErr != nil {
+		details = st.Message()
+	}
+	return fmt.Errorf("status: %s\n%s", st.Code(), details)
+}
@@ -0,0 +1,115 @@
+// Command gomicro-call calls the grpc services having server reflection enabled, see rpc.WithReflection.
+//
+//	gomicro-call [flags] <target> list [service]
+//	gomicro-call [flags] <target> describe <symbol>
+//	gomicro-call [flags] <target> <package.Service/Method> [json|-]
+//
+// The target is host:port, or the service name resolved by etcd/consul with -etcd/-consul.
+// The request is read from stdin if it is "-", an empty message if omitted.
+//
+// Examples:
+//
+//	gomicro-call 127.0.0.1:1701 list
+//	gomicro-call 127.0.0.1:1701 describe pb.HelloRequest
+//	gomicro-call -H 'guid: 42' -timeout 3s 127.0.0.1:1701 pb.HelloService/NormalHello '{"greeting": "world"}'
+//	gomicro-call -etcd http://127.0.0.1:2379 hello_service list
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gomicro/cmd/internal/dial"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// headers is the repeatable -H flag
+type headers []string
+
+func (h *headers) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headers) Set(v string) error {
+	if !strings.Contains(v, ":") {
+		return fmt.Errorf("header %q is not 'key: value'", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+func (h headers) metadata() metadata.MD {
+	md := metadata.MD{}
+	for _, kv := range h {
+		i := strings.Index(kv, ":")
+		k := strings.ToLower(strings.TrimSpace(kv[:i]))
+		md[k] = append(md[k], strings.TrimSpace(kv[i+1:]))
+	}
+	return md
+}
+
+var (
+	etcdAddr   = flag.String("etcd", "", "etcd address to resolve the target service name, e.g. http://127.0.0.1:2379")
+	consulAddr = flag.String("consul", "", "consul address to resolve the target service name, e.g. 127.0.0.1:8500")
+	timeout    = flag.Duration("timeout", 10*time.Second, "deadline of the call")
+	header     headers
+)
+
+func main() {
+	flag.Var(&header, "H", "metadata 'key: value' of the call, repeatable")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <target> list [service] | describe <symbol> | <package.Service/Method> [json|-]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conn, err := dial.Dial(args[0], *etcdAddr, *consulAddr)
+	if err != nil {
+		fatalf("dial %s error: %v", args[0], err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, header.metadata())
+
+	c := newCaller(ctx, conn)
+	defer c.close()
+
+	switch args[1] {
+	case "list":
+		err = c.list(args[2:])
+	case "describe":
+		if len(args) < 3 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = c.describe(args[2])
+	default:
+		request := ""
+		if len(args) > 2 {
+			request = args[2]
+		}
+		err = c.invoke(args[1], request)
+	}
+
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func fatalf(format string, v ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+	os.Exit(1)
+}
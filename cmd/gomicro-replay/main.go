@@ -0,0 +1,119 @@
+// Command gomicro-replay replays the calls recorded by the record package against a target,
+// diffs the responses with the recorded ones, and reports the mismatches & latencies.
+// The target must have server reflection enabled, see rpc.WithReflection.
+//
+//	gomicro-replay [flags] <target> <recording>...
+//
+// The target is host:port, or the service name resolved by etcd/consul with -etcd/-consul.
+// The rotated recordings are replayed in the given order, pass the oldest first,
+// e.g. record.log.2 record.log.1 record.log. Stream calls are skipped.
+//
+// Examples:
+//
+//	gomicro-replay 127.0.0.1:1701 record.log
+//	gomicro-replay -ignore header.request_id,items.updated_at -rate 50 -etcd http://127.0.0.1:2379 hello_service record.log
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gomicro/cmd/internal/dial"
+	"gomicro/rpc/record"
+)
+
+var (
+	etcdAddr   = flag.String("etcd", "", "etcd address to resolve the target service name, e.g. http://127.0.0.1:2379")
+	consulAddr = flag.String("consul", "", "consul address to resolve the target service name, e.g. 127.0.0.1:8500")
+	timeout    = flag.Duration("timeout", 10*time.Second, "deadline of each call")
+	ignore     = flag.String("ignore", "", "comma separated response fields not to diff, e.g. header.request_id,items.updated_at")
+	rate       = flag.Float64("rate", 0, "max calls per second, no limit if 0")
+	sendMD     = flag.Bool("metadata", true, "send the recorded metadata")
+	verbose    = flag.Bool("v", false, "print every replayed call")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <target> <recording>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conn, err := dial.Dial(args[0], *etcdAddr, *consulAddr)
+	if err != nil {
+		fatalf("dial %s error: %v", args[0], err)
+	}
+	defer conn.Close()
+
+	var fields []string
+	if *ignore != "" {
+		fields = strings.Split(*ignore, ",")
+	}
+	r := newReplayer(conn, fields)
+	defer r.close()
+
+	var tick <-chan time.Time
+	if *rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for _, path := range args[1:] {
+		file, err := os.Open(path)
+		if err != nil {
+			fatalf("open %s error: %v", path, err)
+		}
+		n := 0
+		err = record.ReadEntries(file, func(e *record.Entry) error {
+			n++
+			if tick != nil {
+				<-tick
+			}
+			r.replay(fmt.Sprintf("%s:%d", path, n), e)
+			return nil
+		})
+		file.Close()
+		if err != nil {
+			fatalf("read %s error: %v", path, err)
+		}
+	}
+
+	if r.report() {
+		os.Exit(1)
+	}
+}
+
+// percentile of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func sortDurations(d []time.Duration) {
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+}
+
+func fatalf(format string, v ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+	os.Exit(1)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gomicro/rpc/record"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+var js = &jsonpb.Marshaler{OrigName: true}
+
+// stats of a method
+type stats struct {
+	calls      int
+	mismatches int
+	skipped    int
+	recorded   []time.Duration
+	replayed   []time.Duration
+}
+
+// replayer replays the entries by server reflection
+type replayer struct {
+	client  *grpcreflect.Client
+	stub    grpcdynamic.Stub
+	ignore  []string
+	methods map[string]*desc.MethodDescriptor
+	stats   map[string]*stats
+}
+
+func newReplayer(conn *grpc.ClientConn, ignore []string) *replayer {
+	return &replayer{
+		client:  grpcreflect.NewClient(context.Background(), rpb.NewServerReflectionClient(conn)),
+		stub:    grpcdynamic.NewStub(conn),
+		ignore:  ignore,
+		methods: make(map[string]*desc.MethodDescriptor),
+		stats:   make(map[string]*stats),
+	}
+}
+
+func (r *replayer) close() {
+	r.client.Reset()
+}
+
+// method resolves the descriptor of /package.Service/Method
+func (r *replayer) method(fullMethod string) (*desc.MethodDescriptor, error) {
+	if m, ok := r.methods[fullMethod]; ok {
+		return m, nil
+	}
+
+	name := strings.Trim(fullMethod, "/")
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid method %s", fullMethod)
+	}
+	service, err := r.client.ResolveService(name[:i])
+	if err != nil {
+		return nil, fmt.Errorf("resolve service %s error: %v", name[:i], err)
+	}
+	m := service.FindMethodByName(name[i+1:])
+	if m == nil {
+		return nil, fmt.Errorf("method %s not found in %s", name[i+1:], name[:i])
+	}
+	r.methods[fullMethod] = m
+	return m, nil
+}
+
+// replay the entry, pos is where it is recorded for the report
+func (r *replayer) replay(pos string, e *record.Entry) {
+	s, ok := r.stats[e.Method]
+	if !ok {
+		s = &stats{}
+		r.stats[e.Method] = s
+	}
+	s.calls++
+
+	diffs, cost, err := r.call(e)
+	switch {
+	case err != nil:
+		s.skipped++
+		fmt.Printf("SKIP %s %s: %v\n", pos, e.Method, err)
+		return
+	case len(diffs) > 0:
+		s.mismatches++
+		fmt.Printf("MISMATCH %s %s:\n\t%s\n", pos, e.Method, strings.Join(diffs, "\n\t"))
+	case *verbose:
+		fmt.Printf("OK %s %s, recorded=%v, replayed=%v\n", pos, e.Method, seconds(e.Duration), cost)
+	}
+	s.recorded = append(s.recorded, seconds(e.Duration))
+	s.replayed = append(s.replayed, cost)
+}
+
+// call replays the entry, returns the differences from the recorded status & response
+func (r *replayer) call(e *record.Entry) ([]string, time.Duration, error) {
+	m, err := r.method(e.Method)
+	if err != nil {
+		return nil, 0, err
+	}
+	if m.IsClientStreaming() || m.IsServerStreaming() {
+		return nil, 0, fmt.Errorf("stream method is not supported")
+	}
+
+	req := dynamic.NewMessage(m.GetInputType())
+	if err := e.DecodeRequest(req); err != nil {
+		return nil, 0, fmt.Errorf("decode request error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if *sendMD && len(e.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.MD(e.Metadata))
+	}
+
+	start := time.Now()
+	resp, err := r.stub.InvokeRpc(ctx, m, req)
+	cost := time.Since(start)
+
+	if code := grpc.Code(err).String(); code != e.Code {
+		return []string{fmt.Sprintf("code: %s != %s (%s)", e.Code, code, grpc.ErrorDesc(err))}, cost, nil
+	}
+	if err != nil {
+		return nil, cost, nil
+	}
+
+	expected := dynamic.NewMessage(m.GetOutputType())
+	if err := e.DecodeResponse(expected); err != nil {
+		return nil, 0, fmt.Errorf("decode response error: %v", err)
+	}
+	x, err := marshal(expected)
+	if err != nil {
+		return nil, 0, err
+	}
+	y, err := marshal(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	diffs, err := record.Diff(x, y, r.ignore)
+	return diffs, cost, err
+}
+
+func marshal(m proto.Message) ([]byte, error) {
+	s, err := js.MarshalToString(m)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response error: %v", err)
+	}
+	return []byte(s), nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// report prints the stats of the methods, returns true if any call mismatches
+func (r *replayer) report() bool {
+	names := make([]string, 0, len(r.stats))
+	for name := range r.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mismatched := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nMETHOD\tCALLS\tMISMATCH\tSKIP\tRECORDED P50/P99\tREPLAYED P50/P99")
+	for _, name := range names {
+		s := r.stats[name]
+		sortDurations(s.recorded)
+		sortDurations(s.replayed)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%v/%v\t%v/%v\n", name, s.calls, s.mismatches, s.skipped,
+			percentile(s.recorded, 0.5), percentile(s.recorded, 0.99),
+			percentile(s.replayed, 0.5), percentile(s.replayed, 0.99))
+		mismatched = mismatched || s.mismatches > 0
+	}
+	w.Flush()
+	return mismatched
+}
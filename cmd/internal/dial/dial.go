@@ -0,0 +1,25 @@
+// Package dial connects the commands to the grpc services, by host:port or by the
+// service name resolved from etcd/consul.
+package dial
+
+import (
+	consul "gomicro/naming/consul"
+	naming "gomicro/naming/etcd"
+
+	"google.golang.org/grpc"
+)
+
+// Dial the target by host:port, or by the service name resolved from etcd if etcdAddr is set,
+// or from consul if consulAddr is set
+func Dial(target, etcdAddr, consulAddr string) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	switch {
+	case etcdAddr != "":
+		opts = append(opts, grpc.WithBalancer(grpc.RoundRobin(naming.NewResolver(target))))
+		target = etcdAddr
+	case consulAddr != "":
+		opts = append(opts, grpc.WithBalancer(grpc.RoundRobin(consul.NewResolver(target))))
+		target = consulAddr
+	}
+	return grpc.Dial(target, opts...)
+}
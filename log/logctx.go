@@ -11,6 +11,19 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+type idFunc struct {
+	name string
+	f    func(ctx context.Context) string
+}
+
+var idFuncs []idFunc
+
+// RegisterIDFunc 为上下文日志的唯一标识追加 name=f(ctx)，例如trace ID，f返回空串时不追加。
+// 需在init中调用
+func RegisterIDFunc(name string, f func(ctx context.Context) string) {
+	idFuncs = append(idFuncs, idFunc{name: name, f: f})
+}
+
 // 生成日志跟踪的唯一标识
 func getTracerIDFromCtx(ctx context.Context) string {
 	guid := uuid.New()
@@ -21,7 +34,12 @@ func getTracerIDFromCtx(ctx context.Context) string {
 
 	if meta, ok := metadata.FromContext(ctx); ok {
 		if meta["guid"] != nil && len(meta["guid"]) > 0 {
-			return meta["guid"][0]
+			guid = meta["guid"][0]
+		}
+	}
+	for _, id := range idFuncs {
+		if v := id.f(ctx); v != "" {
+			guid += " " + id.name + "=" + v
 		}
 	}
 	return guid
@@ -188,6 +206,14 @@ func CtxWarn(ctx context.Context, v ...interface{}) {
 	Std.Output(getTracerIDFromCtx(ctx), Lwarn, 2, fmt.Sprintln(v...))
 }
 
+// CtxLevelf 控制台按指定级别输出日志
+func CtxLevelf(ctx context.Context, lvl int, format string, v ...interface{}) {
+	if lvl < Std.Level {
+		return
+	}
+	Std.Output(getTracerIDFromCtx(ctx), lvl, 2, fmt.Sprintf(format, v...))
+}
+
 // CtxErrorf 控制台输出日志
 func CtxErrorf(ctx context.Context, format string, v ...interface{}) {
 	Std.Output(getTracerIDFromCtx(ctx), Lerror, 2, fmt.Sprintf(format, v...))
@@ -382,6 +382,13 @@ func SetOutput(w io.Writer) {
 	Std.out = w
 }
 
+// Writer returns the output destination for the standard logger.
+func Writer() io.Writer {
+	Std.mu.Lock()
+	defer Std.mu.Unlock()
+	return Std.out
+}
+
 // Flags returns the output flags for the standard logger.
 func Flags() int {
 	return Std.Flags()
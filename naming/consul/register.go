@@ -1,17 +1,35 @@
 package consul
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"gomicro/naming/lib"
+
 	consul "github.com/hashicorp/consul/api"
 )
 
+var (
+	// HealthCheck reports the health of the service, the ttl check is updated to
+	// "critical" when it returns false, e.g. the Serving method of rpc/health.Server
+	HealthCheck func() bool
+
+	registered *registration
+)
+
+// registration is the service registered by Register
+type registration struct {
+	client    *consul.Client
+	serviceID string
+	stop      chan struct{} // stops updating ttl
+	stopped   chan struct{} // closed when the ttl goroutine returns
+}
+
 // Register is the helper function to self-register service into Etcd/Consul server
+// should call UnRegister when process stop, e.g. as a pre-stop hook of rpc.Run,
+// one service can be registered at a time
 // name - service name
 // host - service host
 // port - service port
@@ -19,54 +37,18 @@ import (
 // interval - interval of self-register to etcd
 // ttl - ttl of the register information
 func Register(name string, host string, port int, target string, interval time.Duration, ttl int) error {
+	if registered != nil {
+		return errors.New("naming: service already registered")
+	}
+
 	config := &consul.Config{Scheme: "http", Address: target}
 	client, err := consul.NewClient(config)
 	if err != nil {
 		return fmt.Errorf("naming: create consul client error: %v", err)
 	}
 
-	serviceID := fmt.Sprintf("%s-%s-%d", name, host, port)
-	// unregister if meet signhup
-	go func() {
-		signalChannel := make(chan os.Signal, 1)
-		// register the signals
-		signal.Notify(signalChannel, syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL, syscall.SIGHUP, syscall.SIGQUIT)
-		// receive a signal
-		receivedSignal := <-signalChannel
-		log.Println("naming: receive signal: ", receivedSignal)
-
-		// unregister the service
-		err := client.Agent().ServiceDeregister(serviceID)
-		if err != nil {
-			log.Println("naming: unregister service error: ", err.Error())
-		} else {
-			log.Println("naming: unregistered service from consul server.")
-		}
-
-		// check if the service is unregistered
-		err = client.Agent().CheckDeregister(serviceID)
-		if err != nil {
-			log.Println("naming: unregister check error: ", err.Error())
-		}
-
-		// exit the process
-		os.Exit(-1)
-	}()
-
-	// goroutine to update ttl
-	go func() {
-		ticker := time.NewTicker(interval)
-		for {
-			// capture a tick point
-			<-ticker.C
-			err = client.Agent().UpdateTTL(serviceID, "", "passing")
-			if err != nil {
-				log.Println("naming: update ttl of service error: ", err.Error())
-			}
-		}
-	}()
-
 	// inital register service
+	serviceID := fmt.Sprintf("%s-%s-%d", name, host, port)
 	serviceRegister := &consul.AgentServiceRegistration{
 		ID:      serviceID,
 		Name:    name,
@@ -87,8 +69,73 @@ func Register(name string, host string, port int, target string, interval time.D
 		AgentServiceCheck: check,
 	})
 	if err != nil {
+		// the service without its check would never expire
+		if e := client.Agent().ServiceDeregister(serviceID); e != nil {
+			log.Println("naming: unregister service error: ", e.Error())
+		}
 		return fmt.Errorf("naming: initial register service check to consul error: %s", err.Error())
 	}
 
+	registered = &registration{client: client, serviceID: serviceID, stop: make(chan struct{}), stopped: make(chan struct{})}
+	stop, stopped := registered.stop, registered.stopped
+
+	// goroutine to update ttl
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// capture a tick point, or stop when unregistered
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+			status := "passing"
+			if HealthCheck != nil && !HealthCheck() {
+				status = "critical"
+			}
+			err := client.Agent().UpdateTTL(serviceID, "", status)
+			if err != nil {
+				log.Println("naming: update ttl of service error: ", err.Error())
+			}
+		}
+	}()
+
+	lib.AddRegistration(lib.Registration{
+		Backend: "consul",
+		Name:    name,
+		ID:      serviceID,
+		Addr:    fmt.Sprintf("%s:%d", host, port),
+		Target:  target,
+	})
 	return nil
 }
+
+// UnRegister delete service & check from consul
+func UnRegister() error {
+	if registered == nil {
+		return errors.New("naming: no service registered")
+	}
+	// wait for the update in progress, or it would pass the check again
+	close(registered.stop)
+	<-registered.stopped
+	client, serviceID := registered.client, registered.serviceID
+	registered = nil
+	lib.RemoveRegistration("consul", serviceID)
+
+	// unregister the service
+	err := client.Agent().ServiceDeregister(serviceID)
+	if err != nil {
+		log.Println("naming: unregister service error: ", err.Error())
+	} else {
+		log.Println("naming: unregistered service from consul server.")
+	}
+
+	// check if the service is unregistered
+	if e := client.Agent().CheckDeregister(serviceID); e != nil {
+		log.Println("naming: unregister check error: ", e.Error())
+	}
+
+	return err
+}
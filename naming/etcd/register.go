@@ -7,14 +7,23 @@ import (
 	"strings"
 	"time"
 
+	"gomicro/naming/lib"
+
 	etcd "github.com/coreos/etcd/client"
 )
 
 var (
 	// Prefix for the service key in etcd
-	Prefix     = "naming"
+	Prefix = "naming"
+	// HealthCheck reports the health of the service, the register information is not
+	// refreshed when it returns false and expires after ttl, e.g. the Serving method of rpc/health.Server
+	HealthCheck func() bool
+
 	keyAPI     etcd.KeysAPI
 	serviceKey string
+	serviceID  string
+	stop       chan struct{} // stops the self-register goroutine
+	stopped    chan struct{} // closed when the self-register goroutine returns
 )
 
 // Register is the helper function to self-register service into Etcd/Consul server
@@ -38,18 +47,32 @@ func Register(name string, host string, port int, target string, interval time.D
 	// new a keys api
 	keyAPI = etcd.NewKeysAPI(client)
 
-	serviceID := fmt.Sprintf("%s-%s-%d", name, host, port)
+	serviceID = fmt.Sprintf("%s-%s-%d", name, host, port)
 	serviceKey = fmt.Sprintf("/%s/%s/%s", Prefix, name, serviceID)
 	hostKey := fmt.Sprintf("/%s/%s/%s/host", Prefix, name, serviceID)
 	portKey := fmt.Sprintf("/%s/%s/%s/port", Prefix, name, serviceID)
 
+	stop, stopped = make(chan struct{}), make(chan struct{})
+	done, exited := stop, stopped
+
 	go func() {
+		defer close(exited)
 		// invoke self-register with ticker
 		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
 
 		for {
-			// wait for next tick point
-			<-ticker.C
+			// wait for next tick point, or stop when unregistered
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+
+			// let the register information expire, re-register when healthy again
+			if HealthCheck != nil && !HealthCheck() {
+				continue
+			}
 
 			_, err := keyAPI.Get(context.Background(), serviceKey, &etcd.GetOptions{Recursive: true})
 			if err != nil {
@@ -93,17 +116,33 @@ func Register(name string, host string, port int, target string, interval time.D
 		log.Printf("naming: set service '%s' ttl to etcd error: %s\n", name, err.Error())
 	}
 
+	lib.AddRegistration(lib.Registration{
+		Backend: "etcd",
+		Name:    name,
+		ID:      serviceID,
+		Addr:    fmt.Sprintf("%s:%d", host, port),
+		Target:  target,
+	})
 	return nil
 }
 
 // UnRegister delete service from etcd
 func UnRegister() error {
+	// stop self-registering first and wait for the tick in progress,
+	// or the service would be registered again
+	if stop != nil {
+		close(stop)
+		<-stopped
+		stop, stopped = nil, nil
+	}
+
 	_, err := keyAPI.Delete(context.Background(), serviceKey, &etcd.DeleteOptions{Recursive: true})
 	if err != nil {
 		log.Println("naming: unregister service error: ", err.Error())
 	} else {
 		log.Println("naming: unregistered service from etcd server.")
 	}
+	lib.RemoveRegistration("etcd", serviceID)
 
 	return err
 }
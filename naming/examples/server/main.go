@@ -9,6 +9,7 @@ import (
 
 	naming "gomicro/naming/etcd"
 	"gomicro/naming/examples/pb"
+	"gomicro/rpc"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -37,7 +38,15 @@ func main() {
 	log.Printf("starting hello service at %d", *port)
 	s := grpc.NewServer()
 	pb.RegisterHelloServiceServer(s, &HelloServer{})
-	s.Serve(listener)
+
+	// unregister from etcd before draining, the clients stop sending new requests
+	err = rpc.Run(context.Background(), s, listener, rpc.RunOptions{
+		PreStop: []func(context.Context) error{
+			func(context.Context) error { return naming.UnRegister() },
+		},
+		DrainDelay: 3 * time.Second,
+	})
+	log.Printf("hello service stopped: %v", err)
 }
 
 // HelloServer 创建对象，实现服务
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registration is a service registered to etcd/consul by this process
+type Registration struct {
+	Backend      string    `json:"backend"` // etcd or consul
+	Name         string    `json:"name"`
+	ID           string    `json:"id"`
+	Addr         string    `json:"addr"`
+	Target       string    `json:"target"` // dial address of the backend
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+var (
+	registrationsLock sync.RWMutex
+	registrations     = make(map[string]Registration)
+)
+
+// AddRegistration records the registration, called by Register of the backends
+func AddRegistration(r Registration) {
+	registrationsLock.Lock()
+	defer registrationsLock.Unlock()
+
+	r.RegisteredAt = time.Now()
+	registrations[r.Backend+"/"+r.ID] = r
+}
+
+// RemoveRegistration forgets the registration, called by UnRegister of the backends
+func RemoveRegistration(backend, id string) {
+	registrationsLock.Lock()
+	defer registrationsLock.Unlock()
+
+	delete(registrations, backend+"/"+id)
+}
+
+// Registrations returns the current registrations of the process
+func Registrations() []Registration {
+	registrationsLock.RLock()
+	defer registrationsLock.RUnlock()
+
+	list := make([]Registration, 0, len(registrations))
+	for _, r := range registrations {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Backend+list[i].ID < list[j].Backend+list[j].ID })
+	return list
+}
@@ -3,6 +3,8 @@ package rc
 import (
 	"gomicro/log"
 	naming "gomicro/naming/etcd"
+	"gomicro/rpc"
+	"gomicro/rpc/health"
 
 	"fmt"
 
@@ -14,8 +16,18 @@ import (
 
 var (
 	serviceConns = newSafeMap()
+	builders     []InterceptorBuilder
 )
 
+// InterceptorBuilder builds the client interceptor for the connection of a service
+type InterceptorBuilder func(serviceName string) grpc.UnaryClientInterceptor
+
+// Use registers client interceptors, they are chained in order for the connections
+// started by StartServiceConns after, e.g. rc.Use(breakers.UnaryClientInterceptor)
+func Use(interceptors ...InterceptorBuilder) {
+	builders = append(builders, interceptors...)
+}
+
 // StartServiceConns start grpc connections with balancer
 func StartServiceConns(address string, serviceList []string) {
 	for _, serviceName := range serviceList {
@@ -25,8 +37,17 @@ func StartServiceConns(address string, serviceList []string) {
 			// new a grpc balancer
 			balancer := grpc.RoundRobin(resolver)
 
+			opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithBalancer(balancer)}
+			if len(builders) > 0 {
+				var interceptors []grpc.UnaryClientInterceptor
+				for _, build := range builders {
+					interceptors = append(interceptors, build(name))
+				}
+				opts = append(opts, grpc.WithUnaryInterceptor(rpc.UnaryClientInterceptorChain(interceptors...)))
+			}
+
 			// new a grpc connection and buffer the connection
-			conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBalancer(balancer))
+			conn, err := grpc.Dial(address, opts...)
 			if err != nil {
 				log.Printf("connect to '%s' service failed: %v", name, err)
 			}
@@ -35,6 +56,19 @@ func StartServiceConns(address string, serviceList []string) {
 	}
 }
 
+// Checker returns the health checker of the connection to the service,
+// e.g. h.AddChecker("", "user_service", rc.Checker("user_service"))
+func Checker(serviceName string) health.Checker {
+	return health.ConnChecker(func() *grpc.ClientConn {
+		return serviceConns.Get(serviceName)
+	})
+}
+
+// Conn returns the connection to the service started by StartServiceConns, nil if not connected yet
+func Conn(serviceName string) *grpc.ClientConn {
+	return serviceConns.Get(serviceName)
+}
+
 // CloseServiceConns close all established connections
 func CloseServiceConns() {
 	for _, conn := range serviceConns.List() {
@@ -0,0 +1,153 @@
+// Package admin is the admin http server of a grpc service, listening apart from the
+// grpc port. It serves prometheus metrics, pprof, the registered methods & interceptors,
+// build info, the log level and the naming registrations. The channelz service is served
+// on the grpc server, for the tools like grpcdebug.
+package admin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+
+	"gomicro/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
+)
+
+// Options of the admin server
+type Options struct {
+	// Addr to listen, e.g. ":9090"
+	Addr string
+	// Server is the grpc server to describe, optional
+	Server *grpc.Server
+	// Username & Password of basic auth, no auth if Username is empty
+	Username string
+	Password string
+	// Gatherer of the metrics, prometheus.DefaultGatherer if nil
+	Gatherer prometheus.Gatherer
+	// Channelz registers the grpc.channelz.v1.Channelz service on Server, it costs a little
+	// on every call, and New must be called before Server serves
+	Channelz bool
+}
+
+// Admin is the admin http server
+type Admin struct {
+	opts   Options
+	mux    *http.ServeMux
+	server *http.Server
+
+	lock  sync.Mutex
+	pages map[string]string // pattern -> description
+}
+
+// New return an Admin with the builtin pages
+func New(opts Options) *Admin {
+	if opts.Gatherer == nil {
+		opts.Gatherer = prometheus.DefaultGatherer
+	}
+
+	a := &Admin{opts: opts, mux: http.NewServeMux(), pages: make(map[string]string)}
+	a.server = &http.Server{Addr: opts.Addr, Handler: a}
+
+	a.HandleFunc("/", "index of the admin pages", a.index)
+	a.Handle("/metrics", "prometheus metrics", promhttp.HandlerFor(opts.Gatherer, promhttp.HandlerOpts{}))
+
+	a.HandleFunc("/debug/pprof/", "pprof profiles", pprof.Index)
+	a.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	a.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	a.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	a.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	if opts.Channelz {
+		if opts.Server != nil {
+			channelz.RegisterChannelzServiceToServer(opts.Server)
+		} else {
+			log.Warnf("admin: channelz needs the grpc server")
+		}
+	}
+
+	a.HandleFunc("/rpc/methods", "registered grpc services & methods", a.methods)
+	a.HandleFunc("/rpc/interceptors", "interceptors of the grpc server", a.interceptors)
+	a.HandleFunc("/buildinfo", "version & build info", buildInfo)
+	a.HandleFunc("/loglevel", "GET the log level, POST level=debug|info|warn|error to change it", logLevel)
+	a.HandleFunc("/naming", "current naming registrations", registrations)
+	return a
+}
+
+// Handle registers an extra page
+func (a *Admin) Handle(pattern, description string, handler http.Handler) {
+	a.lock.Lock()
+	a.pages[pattern] = description
+	a.lock.Unlock()
+
+	a.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers an extra page
+func (a *Admin) HandleFunc(pattern, description string, handler func(http.ResponseWriter, *http.Request)) {
+	a.Handle(pattern, description, http.HandlerFunc(handler))
+}
+
+// ServeHTTP checks basic auth and serves the page
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.opts.Username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(a.opts.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(a.opts.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// Start listens on Addr and serves in background
+func (a *Admin) Start() error {
+	listener, err := net.Listen("tcp", a.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("admin: listen %s error: %v", a.opts.Addr, err)
+	}
+
+	log.Printf("admin: serving at %s", listener.Addr())
+	go func() {
+		if err := a.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Errorf("admin: serve error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the admin server gracefully, it is a post-stop hook of rpc.Run
+func (a *Admin) Shutdown(ctx context.Context) error {
+	return a.server.Shutdown(ctx)
+}
+
+func (a *Admin) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	a.lock.Lock()
+	patterns := make([]string, 0, len(a.pages))
+	for pattern := range a.pages {
+		patterns = append(patterns, pattern)
+	}
+	a.lock.Unlock()
+	sort.Strings(patterns)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, pattern := range patterns {
+		fmt.Fprintf(w, "%-24s %s\n", pattern, a.pages[pattern])
+	}
+}
@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gomicro/log"
+	"gomicro/rpc"
+
+	"google.golang.org/grpc/health"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func get(a *Admin, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	return w
+}
+
+func TestBasicAuth(t *testing.T) {
+	a := New(Options{Username: "admin", Password: "secret"})
+
+	if w := get(a, httptest.NewRequest("GET", "/buildinfo", nil)); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("no auth error, get=%d", w.Code)
+	}
+	r := httptest.NewRequest("GET", "/buildinfo", nil)
+	r.SetBasicAuth("admin", "wrong")
+	if w := get(a, r); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password error, get=%d", w.Code)
+	}
+	r.SetBasicAuth("admin", "secret")
+	if w := get(a, r); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"version"`) {
+		t.Errorf("auth error, get=%d %s", w.Code, w.Body)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	defer log.SetOutputLevel(log.GetOutputLevel())
+	log.SetOutputLevel(log.Linfo)
+	a := New(Options{})
+
+	if w := get(a, httptest.NewRequest("GET", "/loglevel", nil)); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"info"`) {
+		t.Errorf("get error, get=%d %s", w.Code, w.Body)
+	}
+
+	r := httptest.NewRequest("PUT", "/loglevel", strings.NewReader("level=DEBUG"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if w := get(a, r); w.Code != http.StatusOK || log.GetOutputLevel() != log.Ldebug {
+		t.Errorf("put error, get=%d %s", w.Code, w.Body)
+	}
+
+	if w := get(a, httptest.NewRequest("POST", "/loglevel?level=verbose", nil)); w.Code != http.StatusBadRequest || log.GetOutputLevel() != log.Ldebug {
+		t.Errorf("unknown level error, get=%d", w.Code)
+	}
+	if w := get(a, httptest.NewRequest("DELETE", "/loglevel", nil)); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("method error, get=%d", w.Code)
+	}
+}
+
+func TestServerPages(t *testing.T) {
+	if w := get(New(Options{}), httptest.NewRequest("GET", "/rpc/methods", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("no server error, get=%d", w.Code)
+	}
+
+	s := rpc.NewServer()
+	pb.RegisterHealthServer(s, health.NewServer())
+	a := New(Options{Server: s, Channelz: true})
+
+	var services []serviceInfo
+	w := get(a, httptest.NewRequest("GET", "/rpc/methods", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &services); err != nil || len(services) != 2 {
+		t.Fatalf("methods error, get=%s %v", w.Body, err)
+	}
+	// sorted by name, the channelz service is registered on the server
+	if services[0].Name != "grpc.channelz.v1.Channelz" || services[1].Name != "grpc.health.v1.Health" || services[1].Methods[0] != "/grpc.health.v1.Health/Check" {
+		t.Errorf("methods error, get=%+v", services)
+	}
+
+	var info rpc.ServerInfo
+	w = get(a, httptest.NewRequest("GET", "/rpc/interceptors", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil || len(info.UnaryInterceptors) == 0 || info.UnaryInterceptors[0] != "gomicro/rpc.Recovery" {
+		t.Errorf("interceptors error, get=%s %v", w.Body, err)
+	}
+
+	rpc.Release(s)
+	if w := get(a, httptest.NewRequest("GET", "/rpc/interceptors", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("released server error, get=%d", w.Code)
+	}
+}
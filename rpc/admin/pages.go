@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gomicro/log"
+	"gomicro/naming/lib"
+	"gomicro/rpc"
+)
+
+var (
+	// Version of the binary, set by -ldflags "-X gomicro/rpc/admin.Version=v1.0.0"
+	Version = "dev"
+	// Commit of the binary, set by -ldflags
+	Commit = ""
+	// BuildTime of the binary, set by -ldflags
+	BuildTime = ""
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "panic", "fatal"}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func buildInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"version":    Version,
+		"commit":     Commit,
+		"build_time": BuildTime,
+		"go_version": runtime.Version(),
+		"platform":   runtime.GOOS + "/" + runtime.GOARCH,
+	})
+}
+
+func logLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "POST", "PUT":
+		name := strings.ToLower(r.FormValue("level"))
+		found := false
+		for lvl, n := range levelNames {
+			if n == name {
+				log.Printf("admin: change log level from %s to %s", levelNames[log.GetOutputLevel()], name)
+				log.SetOutputLevel(lvl)
+				found = true
+			}
+		}
+		if !found {
+			http.Error(w, "unknown level: "+name, http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, map[string]string{"level": levelNames[log.GetOutputLevel()]})
+}
+
+func registrations(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, lib.Registrations())
+}
+
+type serviceInfo struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+}
+
+func (a *Admin) methods(w http.ResponseWriter, r *http.Request) {
+	if a.opts.Server == nil {
+		http.Error(w, "no grpc server", http.StatusNotFound)
+		return
+	}
+
+	var services []serviceInfo
+	for name, info := range a.opts.Server.GetServiceInfo() {
+		s := serviceInfo{Name: name}
+		for _, m := range info.Methods {
+			s.Methods = append(s.Methods, "/"+name+"/"+m.Name)
+		}
+		sort.Strings(s.Methods)
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	writeJSON(w, services)
+}
+
+func (a *Admin) interceptors(w http.ResponseWriter, r *http.Request) {
+	if a.opts.Server == nil {
+		http.Error(w, "no grpc server", http.StatusNotFound)
+		return
+	}
+
+	info, ok := rpc.Info(a.opts.Server)
+	if !ok {
+		http.Error(w, "server is not created by rpc.NewServer or released", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, info)
+}
@@ -0,0 +1,170 @@
+// Package audit records who called which audited method with what request and result.
+// The records are written to a dedicated Sink rather than log.Std, the FileSink appends
+// them as json lines, optionally hash-chained, and Verify (or cmd/gomicro-audit) checks them.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rpc/auth"
+	"gomicro/rpc/internal/match"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// Redacted replaces the values of the redacted fields
+const Redacted = "[REDACTED]"
+
+var js = &jsonpb.Marshaler{OrigName: true}
+
+// Auditor writes the audit records of the calls
+type Auditor struct {
+	sink Sink
+
+	lock     sync.RWMutex
+	conf     Config
+	patterns []string
+}
+
+// New returns an Auditor writing to sink
+func New(sink Sink, conf Config) *Auditor {
+	a := &Auditor{sink: sink}
+	a.Update(conf)
+	return a
+}
+
+// Update replaces the rules at runtime
+func (a *Auditor) Update(conf Config) {
+	patterns := make([]string, len(conf.Rules))
+	for i, r := range conf.Rules {
+		patterns[i] = r.Method
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.conf = conf
+	a.patterns = patterns
+}
+
+func (a *Auditor) rule(fullMethod string) (Rule, bool) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if i := match.Best(a.patterns, fullMethod); i >= 0 && !a.conf.Rules[i].Disabled {
+		return a.conf.Rules[i], true
+	}
+	return Rule{}, false
+}
+
+// UnaryServerInterceptor audits unary calls
+func (a *Auditor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule, ok := a.rule(info.FullMethod)
+		if !ok {
+			return handler(ctx, request)
+		}
+
+		start := time.Now()
+		response, err := handler(ctx, request)
+		a.write(ctx, rule, info.FullMethod, request, start, err)
+		return response, err
+	}
+}
+
+// StreamServerInterceptor audits stream calls, the requests are not recorded
+func (a *Auditor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule, ok := a.rule(info.FullMethod)
+		if !ok {
+			return handler(srv, stream)
+		}
+
+		start := time.Now()
+		err := handler(srv, stream)
+		a.write(stream.Context(), rule, info.FullMethod, nil, start, err)
+		return err
+	}
+}
+
+func (a *Auditor) write(ctx context.Context, rule Rule, fullMethod string, request interface{}, start time.Time, err error) {
+	rec := &Record{
+		Time:      start.UTC(),
+		Principal: "anonymous",
+		Peer:      "unknown",
+		Method:    fullMethod,
+		Code:      grpc.Code(err).String(),
+		Duration:  time.Since(start).Seconds(),
+	}
+	if p := auth.FromContext(ctx); p != nil {
+		rec.Principal, rec.AuthMethod = p.Subject, p.Method
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		rec.Peer = p.Addr.String()
+	}
+	if err != nil {
+		rec.Error = grpc.ErrorDesc(err)
+	}
+	if pb, ok := request.(proto.Message); ok && !rule.OmitRequest {
+		data, e := redact(pb, rule.Redact)
+		if e != nil {
+			// never fall back to the unredacted request
+			data, _ = json.Marshal("marshal request error: " + e.Error())
+		}
+		rec.Request = data
+	}
+
+	service, method := match.Split(fullMethod)
+	if e := a.sink.Write(rec); e != nil {
+		failureCounter.WithLabelValues(service, method).Inc()
+		log.CtxErrorf(ctx, "audit: write record of %s error: %v", fullMethod, e)
+		return
+	}
+	recordCounter.WithLabelValues(service, method).Inc()
+}
+
+// redact returns the json of the request with the fields replaced by Redacted
+func redact(request proto.Message, fields []string) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	if err := js.Marshal(&buf, request); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return buf.Bytes(), nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		redactPath(v, strings.Split(field, "."))
+	}
+	return json.Marshal(v)
+}
+
+func redactPath(v interface{}, path []string) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			redactPath(item, path)
+		}
+	case map[string]interface{}:
+		value, ok := v[path[0]]
+		switch {
+		case !ok:
+		case len(path) == 1:
+			v[path[0]] = Redacted
+		default:
+			redactPath(value, path[1:])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gomicro/rpc/auth"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path, FileSinkOptions{Chain: true})
+	if err != nil {
+		t.Fatalf("new sink error, get=%v", err)
+	}
+	a := New(sink, Config{Rules: []Rule{
+		{Method: "/grpc.health.v1.Health/*", Redact: []string{"service"}},
+		{Method: "/grpc.health.v1.Health/Watch", Disabled: true},
+	}})
+	interceptor := a.UnaryServerInterceptor()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if req.(*pb.HealthCheckRequest).Service == "secret" {
+			return nil, grpc.Errorf(codes.NotFound, "unknown service")
+		}
+		return &pb.HealthCheckResponse{}, nil
+	}
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "alice", Method: "jwt"})
+	interceptor(ctx, &pb.HealthCheckRequest{Service: "secret"}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	interceptor(ctx, &pb.HealthCheckRequest{}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	interceptor(ctx, &pb.HealthCheckRequest{}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, handler)
+	sink.Close()
+
+	// the chain continues after reopen
+	sink, err = NewFileSink(path, FileSinkOptions{Chain: true})
+	if err != nil {
+		t.Fatalf("reopen sink error, get=%v", err)
+	}
+	New(sink, Config{Rules: []Rule{{Method: "*"}}}).UnaryServerInterceptor()(context.Background(),
+		&pb.HealthCheckRequest{}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	sink.Close()
+
+	if _, err := NewFileSink(path, FileSinkOptions{}); err == nil {
+		t.Errorf("unchained sink on chained file error, get=nil")
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var rec Record
+	json.Unmarshal([]byte(lines[0]), &rec)
+	if len(lines) != 3 || rec.Principal != "alice" || rec.Code != "NotFound" || string(rec.Request) != `{"service":"[REDACTED]"}` {
+		t.Errorf("record error, get=%d lines, %+v", len(lines), rec)
+	}
+
+	if n, err := Verify(bytes.NewReader(data)); n != 3 || err != nil {
+		t.Errorf("verify error, get=%d %v", n, err)
+	}
+	tampered := strings.Replace(string(data), "alice", "mallory", 1)
+	if n, err := Verify(strings.NewReader(tampered)); n != 0 || err == nil {
+		t.Errorf("verify tampered error, get=%d %v", n, err)
+	}
+	removed := lines[0] + "\n" + lines[2] + "\n"
+	if n, err := Verify(strings.NewReader(removed)); n != 1 || err == nil {
+		t.Errorf("verify removed error, get=%d %v", n, err)
+	}
+}
+
+func TestRedactPath(t *testing.T) {
+	var v interface{}
+	json.Unmarshal([]byte(`{"card":{"number":"4242","cvc":"123"},"items":[{"token":"a"},{"token":"b"}]}`), &v)
+	for _, field := range []string{"card.number", "items.token", "unknown.field"} {
+		redactPath(v, strings.Split(field, "."))
+	}
+
+	data, _ := json.Marshal(v)
+	if string(data) != `{"card":{"cvc":"123","number":"[REDACTED]"},"items":[{"token":"[REDACTED]"},{"token":"[REDACTED]"}]}` {
+		t.Errorf("redact error, get=%s", data)
+	}
+}
@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"gomicro/rpc/internal/config"
+)
+
+// Rule is the auditing of the methods matching Method
+type Rule struct {
+	// Method is "/pb.PaymentService/Pay", "/pb.PaymentService/*" or "*"
+	Method string `json:"method"`
+	// Disabled turns off the auditing of the methods, e.g. the read-only ones of an audited service
+	Disabled bool `json:"disabled"`
+	// Redact are the request fields replaced by "[REDACTED]", by the proto field names,
+	// e.g. ["password", "card.number"], the fields in repeated messages are redacted too
+	Redact []string `json:"redact"`
+	// OmitRequest records no request at all
+	OmitRequest bool `json:"omit_request"`
+}
+
+// Config of the audit, the most specific rule of a method is applied,
+// methods matching no rule are not audited
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	err := config.Load("audit", path, &conf)
+	return conf, err
+}
@@ -0,0 +1,27 @@
+package audit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	recordCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "audit_records_total",
+			Help:      "Total number of audit records written on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+
+	failureCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "audit_failures_total",
+			Help:      "Total number of audit records failed to be written on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+)
+
+func init() {
+	prometheus.MustRegister(recordCounter, failureCounter)
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Record of an audited call, written as a json line
+type Record struct {
+	// Seq is the sequence number in the sink, starting from 1
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	// Principal is the subject of the authenticated caller, "anonymous" if not authenticated
+	Principal  string          `json:"principal"`
+	AuthMethod string          `json:"auth_method,omitempty"`
+	Peer       string          `json:"peer"`
+	Method     string          `json:"method"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Code       string          `json:"code"`
+	Error      string          `json:"error,omitempty"`
+	// Duration of the call in seconds
+	Duration float64 `json:"duration"`
+	// Prev & Hash chain the records when the sink is chained,
+	// Hash is the sha256 of Prev and the record without Hash
+	Prev string `json:"prev,omitempty"`
+	Hash string `json:"hash,omitempty"`
+}
+
+// hash of the record chained after prev
+func (r *Record) hash() (string, error) {
+	rec := *r
+	rec.Hash = ""
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(r.Prev))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify checks the records read from r are complete and, if chained, not tampered with,
+// returns the number of the valid records, and the first broken one in the error.
+// A file is chained or not as a whole, see NewFileSink.
+func Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordBytes)
+
+	n := 0
+	var last Record
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return n, fmt.Errorf("record %d: %v", n+1, err)
+		}
+		if rec.Seq != last.Seq+1 {
+			return n, fmt.Errorf("record %d: seq %d follows %d, records are missing or reordered", n+1, rec.Seq, last.Seq)
+		}
+
+		chained := n > 0 && last.Hash != "" || n == 0 && rec.Hash != ""
+		switch {
+		case chained && rec.Prev != last.Hash:
+			return n, fmt.Errorf("record %d: seq %d does not chain to seq %d", n+1, rec.Seq, last.Seq)
+		case chained:
+			hash, err := rec.hash()
+			if err != nil {
+				return n, fmt.Errorf("record %d: %v", n+1, err)
+			}
+			if hash != rec.Hash {
+				return n, fmt.Errorf("record %d: seq %d is modified, hash mismatch", n+1, rec.Seq)
+			}
+		case rec.Hash != "" || rec.Prev != "":
+			return n, fmt.Errorf("record %d: seq %d is chained after unchained records", n+1, rec.Seq)
+		}
+
+		last = rec
+		n++
+	}
+	return n, scanner.Err()
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// maxRecordBytes bounds a record line
+const maxRecordBytes = 1 << 20
+
+// Sink receives the audit records, separated from the normal log
+type Sink interface {
+	// Write sets the Seq (& Prev, Hash if chained) of the record and writes it
+	Write(rec *Record) error
+	Close() error
+}
+
+// FileSinkOptions of the FileSink
+type FileSinkOptions struct {
+	// Chain the records by hash for tamper evidence, see Verify
+	Chain bool
+	// Sync flushes every record to the disk before the call returns
+	Sync bool
+}
+
+// FileSink appends the records to a file as json lines, the file is never truncated or rewritten
+type FileSink struct {
+	opts FileSinkOptions
+
+	lock sync.Mutex
+	file *os.File
+	seq  uint64
+	prev string
+}
+
+// NewFileSink opens the file to append, the sequence & chain continue from the last record of the file.
+// A chained sink can not continue a file of unchained records, and vice versa.
+func NewFileSink(path string, opts FileSinkOptions) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: open %s error: %v", path, err)
+	}
+
+	last, err := lastRecord(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("audit: read the last record of %s error: %v", path, err)
+	}
+	if last != nil && opts.Chain != (last.Hash != "") {
+		file.Close()
+		return nil, fmt.Errorf("audit: chain of %s does not match the existing records, use a new file", path)
+	}
+
+	s := &FileSink{opts: opts, file: file}
+	if last != nil {
+		s.seq, s.prev = last.Seq, last.Hash
+	}
+	return s, nil
+}
+
+// lastRecord returns the last record of the file, nil if it is empty
+func lastRecord(file *os.File) (*Record, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+
+	offset := size - maxRecordBytes
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, size-offset)
+	if _, err := file.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	buf = bytes.TrimRight(buf, "\n")
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+	rec := &Record{}
+	if err := json.Unmarshal(buf, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Write implements Sink
+func (s *FileSink) Write(rec *Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rec.Seq = s.seq + 1
+	rec.Prev, rec.Hash = "", ""
+	if s.opts.Chain {
+		rec.Prev = s.prev
+		hash, err := rec.hash()
+		if err != nil {
+			return err
+		}
+		rec.Hash = hash
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if len(data) >= maxRecordBytes {
+		return fmt.Errorf("audit: record of %s is too large, %d bytes", rec.Method, len(data))
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if s.opts.Sync {
+		if err := s.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	s.seq, s.prev = rec.Seq, rec.Hash
+	return nil
+}
+
+// Close implements Sink
+func (s *FileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+// APIKeyHeader is the metadata key carrying the api key
+const APIKeyHeader = "x-api-key"
+
+// APIKeyAuthenticator authenticates callers by static api keys
+type APIKeyAuthenticator struct {
+	Header string                // metadata key, APIKeyHeader if empty
+	Keys   map[string]*Principal // api key -> principal
+}
+
+// NewAPIKeyAuthenticator return APIKeyAuthenticator with the keys
+func NewAPIKeyAuthenticator(keys map[string]*Principal) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{Header: APIKeyHeader, Keys: keys}
+}
+
+// Authenticate checks the api key in metadata
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	header := a.Header
+	if header == "" {
+		header = APIKeyHeader
+	}
+
+	key := firstMetadata(ctx, header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	// compare all of the keys in constant time, not to leak the matched prefix
+	var found *Principal
+	for k, p := range a.Keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found = p
+		}
+	}
+	if found == nil {
+		return nil, errors.New("auth: invalid api key")
+	}
+
+	principal := *found
+	principal.Method = "apikey"
+	return &principal, nil
+}
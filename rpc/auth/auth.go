@@ -0,0 +1,87 @@
+// Package auth is the authentication & per-method authorization interceptor for grpc.
+// An Authenticator extracts the Principal of the caller (bearer JWT, api key or mTLS),
+// the Policy decides which roles may call which method.
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// ErrNoCredentials means the request carries no credentials for the authenticator,
+// the Chain tries the next authenticator when receiving it.
+var ErrNoCredentials = errors.New("auth: no credentials")
+
+// Principal is the authenticated identity of the caller
+type Principal struct {
+	Subject string                 // user id, api key owner or certificate common name
+	Roles   []string               // roles granted to the caller
+	Method  string                 // how the caller was authenticated: jwt, apikey, mtls
+	Claims  map[string]interface{} // raw jwt claims, nil for other methods
+}
+
+// HasRole reports whether the principal owns the role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates the caller of a rpc
+type Authenticator interface {
+	// Authenticate returns ErrNoCredentials if there is nothing to check,
+	// other errors mean the credentials are invalid.
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to Authenticator
+type AuthenticatorFunc func(ctx context.Context) (*Principal, error)
+
+// Authenticate calls f(ctx)
+func (f AuthenticatorFunc) Authenticate(ctx context.Context) (*Principal, error) {
+	return f(ctx)
+}
+
+// Chain tries the authenticators in order, the first one finding credentials wins
+func Chain(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (*Principal, error) {
+		for _, a := range authenticators {
+			principal, err := a.Authenticate(ctx)
+			if err == ErrNoCredentials {
+				continue
+			}
+			return principal, err
+		}
+		return nil, ErrNoCredentials
+	})
+}
+
+type principalKey struct{}
+
+// NewContext returns a context carrying the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal put by the interceptor, nil if anonymous
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// helper function to get the first value of a metadata key
+func firstMetadata(ctx context.Context, key string) string {
+	meta, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := meta[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
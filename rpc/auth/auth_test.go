@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func sign(secret []byte, claims string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("secret")
+	a := NewHMACAuthenticator(secret)
+
+	token := sign(secret, `{"sub":"alice","roles":["admin"]}`)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	p, err := a.Authenticate(ctx)
+	if err != nil || p.Subject != "alice" || !p.HasRole("admin") {
+		t.Errorf("authenticate error, get=%+v, err=%v", p, err)
+	}
+
+	if _, err := a.Verify(sign([]byte("other"), `{"sub":"alice"}`)); err == nil {
+		t.Errorf("token signed by other secret should be rejected")
+	}
+
+	expired := sign(secret, `{"sub":"alice","exp":`+strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)+`}`)
+	if _, err := a.Verify(expired); err == nil {
+		t.Errorf("expired token should be rejected")
+	}
+
+	if _, err := a.Authenticate(context.Background()); err != ErrNoCredentials {
+		t.Errorf("no credentials error expected, get=%v", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := &Policy{Rules: []Rule{
+		{Method: "/pb.HelloService/*", Roles: []string{"admin"}},
+		{Method: "/pb.HelloService/NormalHello", Public: true},
+	}}
+	admin := &Principal{Subject: "alice", Roles: []string{"admin"}}
+	user := &Principal{Subject: "bob", Roles: []string{"user"}}
+
+	if !policy.Allow(nil, "/pb.HelloService/NormalHello") {
+		t.Errorf("public method should allow anonymous")
+	}
+	if policy.Allow(user, "/pb.HelloService/ErrorHello") {
+		t.Errorf("user should not call admin method")
+	}
+	if !policy.Allow(admin, "/pb.HelloService/ErrorHello") {
+		t.Errorf("admin should call admin method")
+	}
+	if policy.Allow(nil, "/pb.OtherService/Hello") || !policy.Allow(user, "/pb.OtherService/Hello") {
+		t.Errorf("unmatched method should require authentication only")
+	}
+}
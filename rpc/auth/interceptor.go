@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"gomicro/log"
+	"gomicro/rpc"
+	"gomicro/rpc/internal/match"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// DefaultSkip are the methods never authenticated: health checking & server reflection
+var DefaultSkip = []string{
+	"/grpc.health.v1.Health/*",
+	"/grpc.reflection.v1alpha.ServerReflection/*",
+	"/grpc.reflection.v1.ServerReflection/*",
+}
+
+// Options of the auth interceptor
+type Options struct {
+	Authenticator Authenticator // how to find the principal
+	Policy        *Policy       // who may call what, nil to only require authentication
+	Skip          []string      // method patterns bypassing auth, DefaultSkip if nil
+}
+
+// UnaryServerInterceptor authenticates & authorizes unary calls, the principal is put in the context
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if opts.skip(info.FullMethod) {
+			return handler(ctx, request)
+		}
+
+		ctx, err := opts.check(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, request)
+	}
+}
+
+// StreamServerInterceptor authenticates & authorizes stream calls, the principal is put in the stream context
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if opts.skip(info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		ctx, err := opts.check(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, rpc.WrapServerStream(stream, ctx))
+	}
+}
+
+func (opts *Options) skip(fullMethod string) bool {
+	skip := opts.Skip
+	if skip == nil {
+		skip = DefaultSkip
+	}
+	return match.Any(skip, fullMethod)
+}
+
+func (opts *Options) check(ctx context.Context, fullMethod string) (context.Context, error) {
+	var principal *Principal
+	if opts.Authenticator != nil {
+		p, err := opts.Authenticator.Authenticate(ctx)
+		switch {
+		case err == ErrNoCredentials:
+			// anonymous, the policy decides
+		case err != nil:
+			log.CtxWarnf(ctx, "authenticate %s failed: %v", fullMethod, err)
+			return ctx, grpc.Errorf(codes.Unauthenticated, "invalid credentials")
+		default:
+			principal = p
+		}
+	}
+
+	if !opts.Policy.Allow(principal, fullMethod) {
+		if principal == nil {
+			return ctx, grpc.Errorf(codes.Unauthenticated, "authentication required for %s", fullMethod)
+		}
+		return ctx, grpc.Errorf(codes.PermissionDenied, "%s is not allowed to call %s", principal.Subject, fullMethod)
+	}
+
+	if principal != nil {
+		ctx = NewContext(ctx, principal)
+	}
+	return ctx, nil
+}
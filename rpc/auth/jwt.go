@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA256 for crypto.Hash
+	_ "crypto/sha512" // register SHA384 & SHA512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// JWTAuthenticator authenticates the "authorization: Bearer <jwt>" metadata
+type JWTAuthenticator struct {
+	Keys       map[string]interface{} // kid -> key, see LoadJWKS
+	Issuer     string                 // expected "iss", not checked if empty
+	Audience   string                 // expected "aud", not checked if empty
+	RolesClaim string                 // claim holding the roles, "roles" if empty
+	Leeway     time.Duration          // clock skew allowed checking exp & nbf
+}
+
+// NewHMACAuthenticator return JWTAuthenticator verifying HS256/384/512 tokens with the secret
+func NewHMACAuthenticator(secret []byte) *JWTAuthenticator {
+	return &JWTAuthenticator{Keys: map[string]interface{}{"": secret}}
+}
+
+// NewJWKSAuthenticator return JWTAuthenticator verifying tokens with the keys in a local JWKS file
+func NewJWKSAuthenticator(path string) (*JWTAuthenticator, error) {
+	keys, err := LoadJWKS(path)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTAuthenticator{Keys: keys}, nil
+}
+
+// Authenticate verifies the bearer token
+func (a *JWTAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	value := firstMetadata(ctx, "authorization")
+	if len(value) < 7 || !strings.EqualFold(value[:7], "bearer ") {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.Verify(strings.TrimSpace(value[7:]))
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Principal{Subject: subject, Roles: a.roles(claims), Method: "jwt", Claims: claims}, nil
+}
+
+// Verify checks the signature & the registered claims of the token, returns the claims
+func (a *JWTAuthenticator) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("auth: malformed jwt")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("auth: malformed jwt header: %v", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("auth: malformed jwt signature: %v", err)
+	}
+	if err := a.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("auth: malformed jwt claims: %v", err)
+	}
+	if err := a.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a *JWTAuthenticator) key(kid string) (interface{}, error) {
+	if key, ok := a.Keys[kid]; ok {
+		return key, nil
+	}
+	// a token without kid is accepted only if there is exactly one key
+	if kid == "" && len(a.Keys) == 1 {
+		for _, key := range a.Keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("auth: unknown jwt key id %q", kid)
+}
+
+func (a *JWTAuthenticator) verifySignature(alg, kid, signed string, signature []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("auth: unsupported jwt algorithm %q", alg)
+	}
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("auth: unsupported jwt algorithm %q", alg)
+	}
+
+	key, err := a.key(kid)
+	if err != nil {
+		return err
+	}
+
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	// the key type must match the algorithm, or a public key could be used as hmac secret
+	switch k := key.(type) {
+	case []byte:
+		if alg[:2] != "HS" {
+			break
+		}
+		mac := hmac.New(hash.New, k)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("auth: invalid jwt signature")
+		}
+		return nil
+
+	case *rsa.PublicKey:
+		if alg[:2] != "RS" {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return errors.New("auth: invalid jwt signature")
+		}
+		return nil
+
+	case *ecdsa.PublicKey:
+		if alg[:2] != "ES" || len(signature)%2 != 0 {
+			break
+		}
+		r := new(big.Int).SetBytes(signature[:len(signature)/2])
+		s := new(big.Int).SetBytes(signature[len(signature)/2:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("auth: invalid jwt signature")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("auth: jwt algorithm %q does not match the key", alg)
+}
+
+func (a *JWTAuthenticator) validate(claims map[string]interface{}) error {
+	now := time.Now()
+
+	if exp, ok := claims["exp"].(float64); ok {
+		if now.After(time.Unix(int64(exp), 0).Add(a.Leeway)) {
+			return errors.New("auth: jwt is expired")
+		}
+	}
+	if nbf, ok := claims["nbf"].(float64); ok {
+		if now.Add(a.Leeway).Before(time.Unix(int64(nbf), 0)) {
+			return errors.New("auth: jwt is not valid yet")
+		}
+	}
+	if a.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.Issuer {
+			return fmt.Errorf("auth: unexpected jwt issuer %q", iss)
+		}
+	}
+	if a.Audience != "" && !containsString(claims["aud"], a.Audience) {
+		return errors.New("auth: jwt audience mismatch")
+	}
+	return nil
+}
+
+// roles accepts both a json array and a space separated string (like "scope")
+func (a *JWTAuthenticator) roles(claims map[string]interface{}) []string {
+	name := a.RolesClaim
+	if name == "" {
+		name = "roles"
+	}
+
+	switch v := claims[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+func containsString(v interface{}, want string) bool {
+	switch v := v.(type) {
+	case string:
+		return v == want
+	case []interface{}:
+		for _, s := range v {
+			if s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
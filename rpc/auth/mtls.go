@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/x509"
+	"errors"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// MTLSAuthenticator authenticates callers by the verified client certificate
+type MTLSAuthenticator struct {
+	// Roles maps the certificate to roles, the organizational units are used if nil
+	Roles func(cert *x509.Certificate) []string
+}
+
+// NewMTLSAuthenticator return MTLSAuthenticator using the organizational units as roles
+func NewMTLSAuthenticator() *MTLSAuthenticator {
+	return &MTLSAuthenticator{}
+}
+
+// Authenticate takes the identity from the peer certificate
+func (a *MTLSAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return nil, ErrNoCredentials
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return nil, ErrNoCredentials
+	}
+	// the server must be configured with tls.RequireAndVerifyClientCert (or VerifyClientCertIfGiven)
+	if len(info.State.VerifiedChains) == 0 {
+		return nil, errors.New("auth: client certificate is not verified")
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+	roles := cert.Subject.OrganizationalUnit
+	if a.Roles != nil {
+		roles = a.Roles(cert)
+	}
+
+	return &Principal{Subject: cert.Subject.CommonName, Roles: roles, Method: "mtls"}, nil
+}
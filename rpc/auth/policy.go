@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"gomicro/rpc/internal/match"
+)
+
+// Rule is the access rule of the methods matching Method
+type Rule struct {
+	// Method is "/pb.HelloService/NormalHello", "/pb.HelloService/*" or "*"
+	Method string `json:"method"`
+	// Public methods may be called anonymously
+	Public bool `json:"public"`
+	// Roles the caller must own one of, any authenticated caller if empty
+	Roles []string `json:"roles"`
+}
+
+// Policy is the declarative per-method authorization policy.
+// The most specific rule wins: exact method > service wildcard > "*".
+// A method matching no rule only requires an authenticated caller.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// rule returns the rule applied to the method, nil if no one matches
+func (p *Policy) rule(fullMethod string) *Rule {
+	if p == nil {
+		return nil
+	}
+
+	patterns := make([]string, len(p.Rules))
+	for i, r := range p.Rules {
+		patterns[i] = r.Method
+	}
+	if i := match.Best(patterns, fullMethod); i >= 0 {
+		return &p.Rules[i]
+	}
+	return nil
+}
+
+// Allow reports whether the principal (nil if anonymous) may call the method
+func (p *Policy) Allow(principal *Principal, fullMethod string) bool {
+	rule := p.rule(fullMethod)
+	if rule != nil && rule.Public {
+		return true
+	}
+	if principal == nil {
+		return false
+	}
+	if rule == nil || len(rule.Roles) == 0 {
+		return true
+	}
+
+	for _, role := range rule.Roles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
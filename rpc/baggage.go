@@ -0,0 +1,248 @@
+package rpc
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"gomicro/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// BaggagePrefix is the reserved metadata prefix of the baggage, e.g. "x-baggage-tenant"
+const BaggagePrefix = "x-baggage-"
+
+// default limits of the baggage
+const (
+	DefaultBaggageMaxKeys       = 16
+	DefaultBaggageMaxValueBytes = 256
+	DefaultBaggageMaxTotalBytes = 4096
+)
+
+type baggageKey struct{}
+
+var baggageDropped = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "grpc",
+		Name:      "baggage_dropped_total",
+		Help:      "Total number of baggage items dropped, not allowed, over the limits or with an invalid key.",
+	}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(baggageDropped)
+}
+
+// validBaggageKey reports whether k can be a metadata key, lower case letters, digits, "-", "_" & ".",
+// and not ending with "-bin" as the values are not binary
+func validBaggageKey(k string) bool {
+	if k == "" || strings.HasSuffix(k, "-bin") {
+		return false
+	}
+	for _, c := range k {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// WithBaggage returns a context carrying the baggage k=v through the call chain,
+// the key is case insensitive, ctx is returned unchanged if the key is not valid in metadata
+func WithBaggage(ctx context.Context, k, v string) context.Context {
+	k = strings.ToLower(k)
+	if !validBaggageKey(k) {
+		baggageDropped.WithLabelValues("invalid").Inc()
+		log.CtxDebugf(ctx, "baggage %q dropped, invalid key", k)
+		return ctx
+	}
+
+	old, _ := ctx.Value(baggageKey{}).(map[string]string)
+	items := make(map[string]string, len(old)+1)
+	for key, value := range old {
+		items[key] = value
+	}
+	items[k] = v
+	return context.WithValue(ctx, baggageKey{}, items)
+}
+
+// Baggage returns the baggage value of k, "" if none
+func Baggage(ctx context.Context, k string) string {
+	if ctx == nil {
+		return ""
+	}
+	items, _ := ctx.Value(baggageKey{}).(map[string]string)
+	return items[strings.ToLower(k)]
+}
+
+// BaggageItems returns a copy of all the baggage
+func BaggageItems(ctx context.Context) map[string]string {
+	items, _ := ctx.Value(baggageKey{}).(map[string]string)
+	all := make(map[string]string, len(items))
+	for k, v := range items {
+		all[k] = v
+	}
+	return all
+}
+
+// LogBaggage prints the baggage keys in the request ID of the Ctx logs, e.g.
+// "[0d1f... tenant=acme]", call it in init
+func LogBaggage(keys ...string) {
+	for _, k := range keys {
+		k := strings.ToLower(k)
+		log.RegisterIDFunc(k, func(ctx context.Context) string { return Baggage(ctx, k) })
+	}
+}
+
+// BaggageOptions of the baggage interceptors
+type BaggageOptions struct {
+	// Allowed keys crossing the boundary, all if empty. Set it on the edge services
+	// to accept the safe keys only, and on the clients of the external services
+	// not to leak the internal ones
+	Allowed []string
+	// MaxKeys, MaxValueBytes & MaxTotalBytes limit the baggage, the items over the limits are dropped
+	MaxKeys       int
+	MaxValueBytes int
+	MaxTotalBytes int
+}
+
+func (o *BaggageOptions) withDefaults() *BaggageOptions {
+	opts := *o
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = DefaultBaggageMaxKeys
+	}
+	if opts.MaxValueBytes <= 0 {
+		opts.MaxValueBytes = DefaultBaggageMaxValueBytes
+	}
+	if opts.MaxTotalBytes <= 0 {
+		opts.MaxTotalBytes = DefaultBaggageMaxTotalBytes
+	}
+	opts.Allowed = make([]string, len(o.Allowed))
+	for i, k := range o.Allowed {
+		opts.Allowed[i] = strings.ToLower(k)
+	}
+	return &opts
+}
+
+// filter returns the items passing the allowlist & the limits, in the order of the keys,
+// the drops are counted rather than logged, a caller may send them on every call
+func (o *BaggageOptions) filter(keys []string, items map[string]string) map[string]string {
+	passed := make(map[string]string)
+	total := 0
+	for _, k := range keys {
+		v := items[k]
+		if len(o.Allowed) > 0 && !contains(o.Allowed, k) {
+			baggageDropped.WithLabelValues("allowlist").Inc()
+			continue
+		}
+		if !validBaggageKey(k) {
+			baggageDropped.WithLabelValues("invalid").Inc()
+			continue
+		}
+		if len(passed) >= o.MaxKeys || len(v) > o.MaxValueBytes || total+len(k)+len(v) > o.MaxTotalBytes {
+			baggageDropped.WithLabelValues("limit").Inc()
+			continue
+		}
+		passed[k] = v
+		total += len(k) + len(v)
+	}
+	return passed
+}
+
+func contains(keys []string, k string) bool {
+	for _, key := range keys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// decode returns ctx with the baggage in the incoming metadata
+func (o *BaggageOptions) decode(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	var keys []string
+	items := make(map[string]string)
+	for k, vs := range md {
+		if !strings.HasPrefix(k, BaggagePrefix) || len(vs) == 0 {
+			continue
+		}
+		v, err := url.PathUnescape(vs[0])
+		if err != nil {
+			continue
+		}
+		k = k[len(BaggagePrefix):]
+		keys = append(keys, k)
+		items[k] = v
+	}
+	if len(keys) == 0 {
+		return ctx
+	}
+
+	sort.Strings(keys)
+	passed := o.filter(keys, items)
+	if len(passed) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, baggageKey{}, passed)
+}
+
+// encode returns ctx with the baggage in the outgoing metadata
+func (o *BaggageOptions) encode(ctx context.Context) context.Context {
+	items, _ := ctx.Value(baggageKey{}).(map[string]string)
+	if len(items) == 0 {
+		return ctx
+	}
+
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for k, v := range o.filter(keys, items) {
+		md[BaggagePrefix+k] = []string{url.PathEscape(v)}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// BaggageServerInterceptor decodes the baggage of the incoming calls
+func BaggageServerInterceptor(opts BaggageOptions) grpc.UnaryServerInterceptor {
+	o := opts.withDefaults()
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(o.decode(ctx), request)
+	}
+}
+
+// BaggageStreamServerInterceptor decodes the baggage of the incoming streams
+func BaggageStreamServerInterceptor(opts BaggageOptions) grpc.StreamServerInterceptor {
+	o := opts.withDefaults()
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, WrapServerStream(stream, o.decode(stream.Context())))
+	}
+}
+
+// BaggageClientInterceptor encodes the baggage of the context to the outgoing calls
+func BaggageClientInterceptor(opts BaggageOptions) grpc.UnaryClientInterceptor {
+	o := opts.withDefaults()
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(o.encode(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// BaggageStreamClientInterceptor encodes the baggage of the context to the outgoing streams
+func BaggageStreamClientInterceptor(opts BaggageOptions) grpc.StreamClientInterceptor {
+	o := opts.withDefaults()
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(o.encode(ctx), desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestBaggage(t *testing.T) {
+	ctx := WithBaggage(context.Background(), "Tenant", "acme corp")
+	ctx = WithBaggage(ctx, "user", "42")
+	ctx = WithBaggage(ctx, "secret", "s3cr3t")
+	ctx = WithBaggage(ctx, "big", strings.Repeat("x", DefaultBaggageMaxValueBytes+1))
+	limit := counterValue(baggageDropped.WithLabelValues("limit"))
+	allowlist := counterValue(baggageDropped.WithLabelValues("allowlist"))
+
+	// encoded by the client, only the allowed keys cross the boundary
+	var out metadata.MD
+	client := BaggageClientInterceptor(BaggageOptions{Allowed: []string{"tenant", "user", "big"}})
+	client(ctx, "/pb.HelloService/NormalHello", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		out, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	if len(out) != 2 || out[BaggagePrefix+"tenant"][0] != "acme%20corp" {
+		t.Errorf("encode error, get=%v", out)
+	}
+	if dropped := counterValue(baggageDropped.WithLabelValues("limit")) - limit; dropped != 1 {
+		t.Errorf("dropped error, get=%v", dropped)
+	}
+
+	// decoded by the server
+	server := BaggageServerInterceptor(BaggageOptions{Allowed: []string{"tenant"}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.HelloService/NormalHello"}
+	server(metadata.NewIncomingContext(context.Background(), out), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		if v := Baggage(ctx, "tenant"); v != "acme corp" {
+			t.Errorf("decode error, get=%q", v)
+		}
+		if v := Baggage(ctx, "user"); v != "" {
+			t.Errorf("allowlist error, get=%q", v)
+		}
+		return nil, nil
+	})
+	if dropped := counterValue(baggageDropped.WithLabelValues("allowlist")) - allowlist; dropped != 2 {
+		t.Errorf("allowlist dropped error, get=%v", dropped)
+	}
+}
+
+func TestBaggageKey(t *testing.T) {
+	invalid := counterValue(baggageDropped.WithLabelValues("invalid"))
+	ctx := WithBaggage(context.Background(), "Tenant", "acme")
+	for _, k := range []string{"", "bad key", "x:y", "trace-bin", "ünicode"} {
+		if c := WithBaggage(ctx, k, "v"); len(BaggageItems(c)) != 1 {
+			t.Errorf("invalid key %q error, get=%v", k, BaggageItems(c))
+		}
+	}
+	if dropped := counterValue(baggageDropped.WithLabelValues("invalid")) - invalid; dropped != 5 {
+		t.Errorf("dropped error, get=%v", dropped)
+	}
+
+	// the items set bypassing WithBaggage are dropped by encode
+	ctx = context.WithValue(ctx, baggageKey{}, map[string]string{"tenant": "acme", "bad key": "v"})
+	md, _ := metadata.FromOutgoingContext((&BaggageOptions{}).withDefaults().encode(ctx))
+	if len(md) != 1 || md[BaggagePrefix+"tenant"][0] != "acme" {
+		t.Errorf("encode error, get=%v", md)
+	}
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	c.Write(&m)
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,252 @@
+// Package breaker is the client side circuit breaker for grpc calls.
+//
+// A Breaker is closed at first and lets all calls pass. It is opened when the calls
+// in the sliding window fail or slow down too much, then every call fails fast with
+// Unavailable. After OpenTimeout it is half-open: a few probe calls are let through,
+// the breaker is closed if all of them succeed, or opened again.
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gomicro/log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// State of the circuit breaker
+type State int
+
+const (
+	// StateClosed lets all calls pass
+	StateClosed State = iota
+	// StateHalfOpen lets a few probe calls pass
+	StateHalfOpen
+	// StateOpen rejects all calls
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// ErrOpen is returned by Allow when the breaker rejects the call
+var ErrOpen = errors.New("breaker: circuit breaker is open")
+
+// Settings of the circuit breaker, a zero trip condition is disabled
+type Settings struct {
+	Window              time.Duration // length of the sliding window, 10s if zero
+	Buckets             int           // buckets of the sliding window, 10 if zero
+	MinRequests         int           // calls needed in the window before checking the ratios
+	ErrorRatio          float64       // trips when failures/total >= ErrorRatio
+	ConsecutiveFailures int           // trips after the consecutive failures
+	SlowCall            time.Duration // calls longer than it are slow
+	SlowCallRatio       float64       // trips when slow/total >= SlowCallRatio
+	OpenTimeout         time.Duration // time staying open before half-open, 30s if zero
+	HalfOpenCalls       int           // probe calls in half-open state, 1 if zero
+
+	// IsFailure reports whether the error counts as a failure, DefaultIsFailure if nil
+	IsFailure func(err error) bool
+	// OnStateChange is called when the state changes, out of the breaker lock
+	OnStateChange func(name string, from, to State)
+}
+
+// DefaultIsFailure treats the errors of an unhealthy server as failures,
+// business errors like InvalidArgument or NotFound do not trip the breaker.
+func DefaultIsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch grpc.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// Breaker is a circuit breaker
+type Breaker struct {
+	name     string
+	settings Settings
+
+	lock        sync.Mutex
+	state       State
+	generation  uint64 // increased on every state change, to drop the results of the former state
+	window      *window
+	consecutive int
+	openedAt    time.Time
+	probes      int // probe calls let through in half-open state
+	successes   int // succeeded probe calls
+}
+
+// New return a closed Breaker
+func New(name string, settings Settings) *Breaker {
+	if settings.Window <= 0 {
+		settings.Window = 10 * time.Second
+	}
+	if settings.Buckets <= 0 {
+		settings.Buckets = 10
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	if settings.HalfOpenCalls <= 0 {
+		settings.HalfOpenCalls = 1
+	}
+	if settings.IsFailure == nil {
+		settings.IsFailure = DefaultIsFailure
+	}
+
+	return &Breaker{
+		name:     name,
+		settings: settings,
+		window:   newWindow(settings.Window, settings.Buckets, time.Now()),
+	}
+}
+
+// Name returns the name of the breaker
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.lock.Lock()
+	state, changed := b.current(time.Now())
+	b.lock.Unlock()
+
+	b.notify(changed)
+	return state
+}
+
+// Allow checks if the call may go, done must be called with the result of the call
+func (b *Breaker) Allow() (done func(err error, cost time.Duration), err error) {
+	now := time.Now()
+
+	b.lock.Lock()
+	state, changed := b.current(now)
+	if state == StateOpen || (state == StateHalfOpen && b.probes >= b.settings.HalfOpenCalls) {
+		b.lock.Unlock()
+		b.notify(changed)
+		return nil, ErrOpen
+	}
+	if state == StateHalfOpen {
+		b.probes++
+	}
+	generation := b.generation
+	b.lock.Unlock()
+	b.notify(changed)
+
+	return func(err error, cost time.Duration) {
+		b.done(generation, err, cost)
+	}, nil
+}
+
+func (b *Breaker) done(generation uint64, err error, cost time.Duration) {
+	now := time.Now()
+	failure := b.settings.IsFailure(err)
+	slow := b.settings.SlowCall > 0 && cost >= b.settings.SlowCall
+
+	b.lock.Lock()
+	state, changed := b.current(now)
+	if generation != b.generation {
+		b.lock.Unlock()
+		b.notify(changed)
+		return
+	}
+
+	switch state {
+	case StateClosed:
+		b.window.record(now, failure, slow)
+		if failure {
+			b.consecutive++
+		} else {
+			b.consecutive = 0
+		}
+		if b.shouldTrip(now) {
+			changed = append(changed, b.setState(StateOpen, now))
+		}
+
+	case StateHalfOpen:
+		if failure || slow {
+			changed = append(changed, b.setState(StateOpen, now))
+			break
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenCalls {
+			changed = append(changed, b.setState(StateClosed, now))
+		}
+	}
+	b.lock.Unlock()
+	b.notify(changed)
+}
+
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	s := &b.settings
+	if s.ConsecutiveFailures > 0 && b.consecutive >= s.ConsecutiveFailures {
+		return true
+	}
+
+	c := b.window.sum(now)
+	if c.total == 0 || c.total < s.MinRequests {
+		return false
+	}
+	if s.ErrorRatio > 0 && float64(c.failures)/float64(c.total) >= s.ErrorRatio {
+		return true
+	}
+	if s.SlowCallRatio > 0 && float64(c.slow)/float64(c.total) >= s.SlowCallRatio {
+		return true
+	}
+	return false
+}
+
+// current returns the state at now, turns open to half-open after the timeout
+func (b *Breaker) current(now time.Time) (State, []transition) {
+	var changed []transition
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.settings.OpenTimeout {
+		changed = append(changed, b.setState(StateHalfOpen, now))
+	}
+	return b.state, changed
+}
+
+type transition struct {
+	from, to State
+}
+
+func (b *Breaker) setState(state State, now time.Time) transition {
+	t := transition{from: b.state, to: state}
+
+	b.state = state
+	b.generation++
+	b.consecutive = 0
+	b.probes = 0
+	b.successes = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.window.reset(now)
+	}
+	return t
+}
+
+func (b *Breaker) notify(changed []transition) {
+	for _, t := range changed {
+		log.Warnf("breaker: '%s' state changed from %s to %s", b.name, t.from, t.to)
+		if b.settings.OnStateChange != nil {
+			b.settings.OnStateChange(b.name, t.from, t.to)
+		}
+	}
+}
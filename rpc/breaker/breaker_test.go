@@ -0,0 +1,79 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func call(b *Breaker, err error) error {
+	done, e := b.Allow()
+	if e != nil {
+		return e
+	}
+	done(err, time.Millisecond)
+	return nil
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	var changes []State
+	b := New("test", Settings{
+		ConsecutiveFailures: 3,
+		OpenTimeout:         50 * time.Millisecond,
+		OnStateChange:       func(name string, from, to State) { changes = append(changes, to) },
+	})
+	unavailable := grpc.Errorf(codes.Unavailable, "down")
+
+	// business errors never trip the breaker
+	for i := 0; i < 5; i++ {
+		call(b, grpc.Errorf(codes.NotFound, "not found"))
+	}
+	for i := 0; i < 3; i++ {
+		call(b, unavailable)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("breaker should be open, get=%s", b.State())
+	}
+	if err := call(b, nil); err != ErrOpen {
+		t.Errorf("open breaker should fail fast, get=%v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("breaker should be half-open, get=%s", b.State())
+	}
+	if err := call(b, nil); err != nil {
+		t.Errorf("probe call should pass, get=%v", err)
+	}
+	if b.State() != StateClosed {
+		t.Errorf("breaker should be closed after the probe, get=%s", b.State())
+	}
+
+	want := []State{StateOpen, StateHalfOpen, StateClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("state changes error, get=%v", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("state changes error, get=%v", changes)
+		}
+	}
+}
+
+func TestErrorRatio(t *testing.T) {
+	b := New("test", Settings{MinRequests: 10, ErrorRatio: 0.5})
+	unavailable := grpc.Errorf(codes.Unavailable, "down")
+
+	for i := 0; i < 9; i++ {
+		call(b, unavailable)
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("breaker should wait for min requests")
+	}
+	call(b, nil)
+	if b.State() != StateOpen {
+		t.Errorf("breaker should be open, get=%s", b.State())
+	}
+}
@@ -0,0 +1,90 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"gomicro/rpc/internal/match"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Group holds one breaker per target service & method
+type Group struct {
+	settings Settings
+
+	lock     sync.RWMutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup return a Group, every breaker is created with the settings
+func NewGroup(settings Settings) *Group {
+	return &Group{settings: settings, breakers: make(map[string]*Breaker)}
+}
+
+// Get returns the breaker of the service & method, creates it if not exists
+func (g *Group) Get(serviceName, fullMethod string) *Breaker {
+	key := serviceName + fullMethod
+
+	g.lock.RLock()
+	b, ok := g.breakers[key]
+	g.lock.RUnlock()
+	if ok {
+		return b
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if b, ok := g.breakers[key]; ok {
+		return b
+	}
+
+	_, method := match.Split(fullMethod)
+	settings := g.settings
+	callback := settings.OnStateChange
+	settings.OnStateChange = func(name string, from, to State) {
+		stateGauge.WithLabelValues(serviceName, method).Set(float64(to))
+		if callback != nil {
+			callback(name, from, to)
+		}
+	}
+
+	b = New(key, settings)
+	g.breakers[key] = b
+	stateGauge.WithLabelValues(serviceName, method).Set(float64(StateClosed))
+	return b
+}
+
+// States returns the state of every breaker, keyed by service name & method
+func (g *Group) States() map[string]State {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	states := make(map[string]State, len(g.breakers))
+	for key, b := range g.breakers {
+		states[key] = b.State()
+	}
+	return states
+}
+
+// UnaryClientInterceptor returns the breaker interceptor for the connection of the service,
+// it matches rc.InterceptorBuilder, so it can be registered by rc.Use(group.UnaryClientInterceptor).
+func (g *Group) UnaryClientInterceptor(serviceName string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		b := g.Get(serviceName, method)
+
+		done, err := b.Allow()
+		if err != nil {
+			_, name := match.Split(method)
+			rejectedCounter.WithLabelValues(serviceName, name).Inc()
+			return grpc.Errorf(codes.Unavailable, "circuit breaker of %s%s is open", serviceName, method)
+		}
+
+		start := time.Now()
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		done(err, time.Since(start))
+		return err
+	}
+}
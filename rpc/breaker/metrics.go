@@ -0,0 +1,27 @@
+package breaker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	stateGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "grpc",
+			Subsystem: "client",
+			Name:      "circuit_breaker_state",
+			Help:      "State of the circuit breaker: 0 closed, 1 half-open, 2 open.",
+		}, []string{"grpc_target", "grpc_method"})
+
+	rejectedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "client",
+			Name:      "circuit_breaker_rejected_total",
+			Help:      "Total number of RPCs failed fast by the open circuit breaker.",
+		}, []string{"grpc_target", "grpc_method"})
+)
+
+func init() {
+	prometheus.MustRegister(stateGauge, rejectedCounter)
+}
@@ -0,0 +1,78 @@
+package breaker
+
+import (
+	"time"
+)
+
+// counts of calls in a period
+type counts struct {
+	total    int
+	failures int
+	slow     int
+}
+
+func (c *counts) add(o counts) {
+	c.total += o.total
+	c.failures += o.failures
+	c.slow += o.slow
+}
+
+// window is the sliding window made of buckets, the oldest bucket is dropped as time goes
+type window struct {
+	size      time.Duration // period of one bucket
+	buckets   []counts
+	head      int       // index of the current bucket
+	headStart time.Time // start time of the current bucket
+}
+
+func newWindow(period time.Duration, n int, now time.Time) *window {
+	if n < 1 {
+		n = 1
+	}
+	return &window{size: period / time.Duration(n), buckets: make([]counts, n), headStart: now}
+}
+
+func (w *window) advance(now time.Time) {
+	// the whole window is outdated
+	if now.Sub(w.headStart) >= w.size*time.Duration(len(w.buckets)) {
+		w.reset(now)
+		return
+	}
+
+	for now.Sub(w.headStart) >= w.size {
+		w.head = (w.head + 1) % len(w.buckets)
+		w.buckets[w.head] = counts{}
+		w.headStart = w.headStart.Add(w.size)
+	}
+}
+
+func (w *window) record(now time.Time, failure, slow bool) {
+	w.advance(now)
+
+	b := &w.buckets[w.head]
+	b.total++
+	if failure {
+		b.failures++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+func (w *window) sum(now time.Time) counts {
+	w.advance(now)
+
+	var c counts
+	for _, b := range w.buckets {
+		c.add(b)
+	}
+	return c
+}
+
+func (w *window) reset(now time.Time) {
+	for i := range w.buckets {
+		w.buckets[i] = counts{}
+	}
+	w.head = 0
+	w.headStart = now
+}
@@ -0,0 +1,270 @@
+// Package cache caches the responses of the read-heavy grpc methods, on the server
+// or on the client. The cache key is the method, the selected metadata and the
+// deterministic marshal of the request. Concurrent identical requests are merged
+// into one call, and only successful responses are cached.
+package cache
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gomicro/rpc/internal/match"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// Cache of the responses
+type Cache struct {
+	lock     sync.Mutex
+	conf     Config
+	patterns []string
+	lru      *lru
+	calls    map[string]*call // in-flight calls by key
+}
+
+// call is an in-flight call shared by the identical requests
+type call struct {
+	done     chan struct{}
+	response proto.Message
+	err      error
+}
+
+// New returns a Cache with the config
+func New(conf Config) *Cache {
+	c := &Cache{lru: newLRU(0), calls: make(map[string]*call)}
+	c.Update(conf)
+	return c
+}
+
+// Update replaces the rules at runtime, the cached responses are kept till their TTL
+func (c *Cache) Update(conf Config) {
+	if conf.MaxEntries <= 0 {
+		conf.MaxEntries = DefaultMaxEntries
+	}
+	patterns := make([]string, len(conf.Rules))
+	for i, r := range conf.Rules {
+		patterns[i] = r.Method
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.conf = conf
+	c.patterns = patterns
+	c.lru.resize(conf.MaxEntries)
+}
+
+func (c *Cache) rule(fullMethod string) (Rule, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if i := match.Best(c.patterns, fullMethod); i >= 0 && c.conf.Rules[i].TTL > 0 {
+		return c.conf.Rules[i], true
+	}
+	return Rule{}, false
+}
+
+// Key returns the cache key of the request, md are the metadata of the call
+func Key(fullMethod string, request interface{}, keys []string, md metadata.MD) (string, bool) {
+	pb, ok := request.(proto.Message)
+	if !ok {
+		return "", false
+	}
+
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(pb); err != nil {
+		return "", false
+	}
+
+	key := fullMethod
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		for _, v := range md[k] {
+			key += "\x00" + k + "=" + v
+		}
+	}
+	return key + "\x00" + string(buf.Bytes()), true
+}
+
+// Invalidate removes the cached response of the request, md are the metadata selected by the rule
+func (c *Cache) Invalidate(fullMethod string, request proto.Message, md metadata.MD) {
+	rule, _ := c.rule(fullMethod)
+	key, ok := Key(fullMethod, request, rule.Metadata, md)
+	if !ok {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.lru.entries[key]; ok {
+		c.lru.remove(e)
+	}
+}
+
+// InvalidateMethod removes the cached responses of the methods matching pattern,
+// e.g. "/pb.HelloService/NormalHello" or "/pb.HelloService/*", returns the number removed
+func (c *Cache) InvalidateMethod(pattern string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.removeFunc(func(e *entry) bool { return match.Method(pattern, e.method) })
+}
+
+// Purge removes all the cached responses
+func (c *Cache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lru.removeFunc(func(*entry) bool { return true })
+}
+
+// Len returns the number of cached responses
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.list.Len()
+}
+
+// errAborted is the result of a call whose f panics
+var errAborted = grpc.Errorf(codes.Aborted, "cached call aborted")
+
+// shareable reports whether the error of the leading call can be returned to the others,
+// the leader's own cancellation or deadline, or its panic, is not
+func shareable(err error) bool {
+	if err == errAborted || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	switch grpc.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded:
+		return false
+	}
+	return true
+}
+
+// do returns the cached response of key, or calls f once for the concurrent identical requests.
+// The response returned is shared, hit reports whether it is a success not called by the caller.
+// The others call again if the leading call ends by its own context.
+func (c *Cache) do(ctx context.Context, key, fullMethod string, ttl time.Duration, f func() (proto.Message, error)) (response proto.Message, hit bool, err error) {
+	for {
+		c.lock.Lock()
+		if response, ok := c.lru.get(key, time.Now()); ok {
+			c.lock.Unlock()
+			return response, true, nil
+		}
+		cl, ok := c.calls[key]
+		if !ok {
+			cl = &call{done: make(chan struct{}), err: errAborted}
+			c.calls[key] = cl
+			c.lock.Unlock()
+			c.lead(cl, key, fullMethod, ttl, f)
+			return cl.response, false, cl.err
+		}
+		c.lock.Unlock()
+
+		select {
+		case <-cl.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		if cl.err == nil {
+			return cl.response, true, nil
+		}
+		if shareable(cl.err) {
+			return nil, false, cl.err
+		}
+	}
+}
+
+// lead calls f for the identical requests, the waiters are released even if f panics
+func (c *Cache) lead(cl *call, key, fullMethod string, ttl time.Duration, f func() (proto.Message, error)) {
+	defer func() {
+		c.lock.Lock()
+		delete(c.calls, key)
+		if cl.err == nil && cl.response != nil {
+			c.lru.add(&entry{key: key, method: fullMethod, response: cl.response, expires: time.Now().Add(ttl)})
+		}
+		c.lock.Unlock()
+		close(cl.done)
+	}()
+
+	cl.response, cl.err = f()
+}
+
+// UnaryServerInterceptor serves the methods having a rule from the cache
+func (c *Cache) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule, ok := c.rule(info.FullMethod)
+		if !ok {
+			return handler(ctx, request)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		key, ok := Key(info.FullMethod, request, rule.Metadata, md)
+		if !ok {
+			return handler(ctx, request)
+		}
+
+		response, hit, err := c.do(ctx, key, info.FullMethod, time.Duration(rule.TTL), func() (proto.Message, error) {
+			response, err := handler(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			pb, _ := response.(proto.Message)
+			return pb, nil
+		})
+
+		service, method := match.Split(info.FullMethod)
+		if hit {
+			serverHits.WithLabelValues(service, method).Inc()
+		} else {
+			serverMisses.WithLabelValues(service, method).Inc()
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the interceptors after may change the response
+		return proto.Clone(response), nil
+	}
+}
+
+// UnaryClientInterceptor serves the calls of the methods having a rule from the cache,
+// it is a rc.InterceptorBuilder, e.g. rc.Use(c.UnaryClientInterceptor)
+func (c *Cache) UnaryClientInterceptor(serviceName string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, fullMethod string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		rule, ok := c.rule(fullMethod)
+		out, isProto := reply.(proto.Message)
+		if !ok || !isProto {
+			return invoker(ctx, fullMethod, req, reply, cc, opts...)
+		}
+		md, _ := metadata.FromOutgoingContext(ctx)
+		key, ok := Key(fullMethod, req, rule.Metadata, md)
+		if !ok {
+			return invoker(ctx, fullMethod, req, reply, cc, opts...)
+		}
+
+		response, hit, err := c.do(ctx, key, fullMethod, time.Duration(rule.TTL), func() (proto.Message, error) {
+			fresh := proto.Clone(out)
+			fresh.Reset()
+			if err := invoker(ctx, fullMethod, req, fresh, cc, opts...); err != nil {
+				return nil, err
+			}
+			return fresh, nil
+		})
+
+		_, method := match.Split(fullMethod)
+		if hit {
+			clientHits.WithLabelValues(serviceName, method).Inc()
+		} else {
+			clientMisses.WithLabelValues(serviceName, method).Inc()
+		}
+		if err != nil {
+			return err
+		}
+		out.Reset()
+		proto.Merge(out, response)
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gomicro/rpc/internal/config"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestServerInterceptor(t *testing.T) {
+	c := New(Config{Rules: []Rule{{Method: "/grpc.health.v1.Health/*", TTL: config.Duration(time.Minute)}}, MaxEntries: 2})
+	interceptor := c.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+
+	var calls int32
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &pb.HealthCheckResponse{Status: pb.HealthCheckResponse_SERVING}, nil
+	}
+	check := func(service string) {
+		response, err := interceptor(context.Background(), &pb.HealthCheckRequest{Service: service}, info, handler)
+		if err != nil || response.(*pb.HealthCheckResponse).Status != pb.HealthCheckResponse_SERVING {
+			t.Errorf("check %s error, get=%v %v", service, response, err)
+		}
+	}
+
+	// concurrent identical requests are merged
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			check("a")
+		}()
+	}
+	wg.Wait()
+	check("a")
+	if calls != 1 {
+		t.Errorf("singleflight error, get=%d calls", calls)
+	}
+
+	// "a" is evicted by "b" & "c"
+	check("b")
+	check("c")
+	check("a")
+	if calls != 4 || c.Len() != 2 {
+		t.Errorf("lru error, get=%d calls, %d entries", calls, c.Len())
+	}
+
+	c.Invalidate(info.FullMethod, &pb.HealthCheckRequest{Service: "a"}, nil)
+	check("a")
+	if calls != 5 {
+		t.Errorf("invalidate error, get=%d calls", calls)
+	}
+
+	if n := c.InvalidateMethod("/grpc.health.v1.Health/*"); n != 2 || c.Len() != 0 {
+		t.Errorf("invalidate method error, get=%d removed, %d entries", n, c.Len())
+	}
+}
+
+func TestClientInterceptor(t *testing.T) {
+	c := New(Config{Rules: []Rule{{Method: "/grpc.health.v1.Health/Check", TTL: config.Duration(20 * time.Millisecond)}}})
+	interceptor := c.UnaryClientInterceptor("health")
+
+	var calls int32
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		atomic.AddInt32(&calls, 1)
+		if req.(*pb.HealthCheckRequest).Service == "unknown" {
+			return grpc.Errorf(codes.NotFound, "unknown service")
+		}
+		reply.(*pb.HealthCheckResponse).Status = pb.HealthCheckResponse_SERVING
+		return nil
+	}
+	check := func(service string) (*pb.HealthCheckResponse, error) {
+		reply := &pb.HealthCheckResponse{}
+		err := interceptor(context.Background(), "/grpc.health.v1.Health/Check", &pb.HealthCheckRequest{Service: service}, reply, nil, invoker)
+		return reply, err
+	}
+
+	check("a")
+	if reply, err := check("a"); err != nil || reply.Status != pb.HealthCheckResponse_SERVING || calls != 1 {
+		t.Errorf("hit error, get=%v %v, %d calls", reply, err, calls)
+	}
+
+	// the response expires after TTL
+	time.Sleep(30 * time.Millisecond)
+	check("a")
+	if calls != 2 {
+		t.Errorf("ttl error, get=%d calls", calls)
+	}
+
+	// errors are not cached
+	check("unknown")
+	if _, err := check("unknown"); grpc.Code(err) != codes.NotFound || calls != 4 || c.Len() != 1 {
+		t.Errorf("error cached, get=%v, %d calls, %d entries", err, calls, c.Len())
+	}
+}
+
+func TestLeaderFailure(t *testing.T) {
+	c := New(Config{})
+	key := "k"
+
+	// the panic of the leader releases the key
+	func() {
+		defer func() { recover() }()
+		c.do(context.Background(), key, "/m", time.Minute, func() (proto.Message, error) { panic("boom") })
+	}()
+	done := make(chan struct{})
+	go func() {
+		c.do(context.Background(), key, "/m", time.Minute, func() (proto.Message, error) { return &pb.HealthCheckResponse{}, nil })
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("panic error, key is stuck")
+	}
+
+	// the followers call again if the leader's own context ends
+	c.Purge()
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	go c.do(ctx, key, "/m", time.Minute, func() (proto.Message, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, grpc.Errorf(codes.Canceled, "canceled")
+	})
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	response, hit, err := c.do(context.Background(), key, "/m", time.Minute, func() (proto.Message, error) {
+		return &pb.HealthCheckResponse{Status: pb.HealthCheckResponse_SERVING}, nil
+	})
+	if err != nil || hit || response.(*pb.HealthCheckResponse).Status != pb.HealthCheckResponse_SERVING {
+		t.Errorf("canceled leader error, get=%v %v %v", response, hit, err)
+	}
+}
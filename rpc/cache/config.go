@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"gomicro/rpc/internal/config"
+)
+
+// Rule is the caching of the methods matching Method
+type Rule struct {
+	// Method is "/pb.HelloService/NormalHello", "/pb.HelloService/*" or "*"
+	Method string `json:"method"`
+	// TTL of the cached responses, the method is not cached if 0
+	TTL config.Duration `json:"ttl"`
+	// Metadata keys to be part of the cache key, e.g. ["x-user-id"]
+	Metadata []string `json:"metadata"`
+}
+
+// Config of the cache, the most specific rule of a method is applied,
+// methods matching no rule are not cached
+type Config struct {
+	Rules []Rule `json:"rules"`
+	// MaxEntries bounds the cache, the least recently used are evicted, DefaultMaxEntries if 0
+	MaxEntries int `json:"max_entries"`
+}
+
+// DefaultMaxEntries is the default bound of the cache
+const DefaultMaxEntries = 10000
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	err := config.Load("cache", path, &conf)
+	return conf, err
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+type entry struct {
+	key      string
+	method   string
+	response proto.Message
+	expires  time.Time
+}
+
+// lru is the least recently used list of the entries, not safe for concurrent use
+type lru struct {
+	max     int
+	list    *list.List
+	entries map[string]*list.Element
+}
+
+func newLRU(max int) *lru {
+	return &lru{max: max, list: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *lru) get(key string, now time.Time) (proto.Message, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(e.Value.(*entry).expires) {
+		c.remove(e)
+		return nil, false
+	}
+	c.list.MoveToFront(e)
+	return e.Value.(*entry).response, true
+}
+
+func (c *lru) add(en *entry) {
+	if e, ok := c.entries[en.key]; ok {
+		e.Value = en
+		c.list.MoveToFront(e)
+		return
+	}
+
+	c.entries[en.key] = c.list.PushFront(en)
+	for c.max > 0 && c.list.Len() > c.max {
+		c.remove(c.list.Back())
+	}
+}
+
+func (c *lru) remove(e *list.Element) {
+	c.list.Remove(e)
+	delete(c.entries, e.Value.(*entry).key)
+}
+
+// removeFunc removes the entries f returns true for
+func (c *lru) removeFunc(f func(*entry) bool) int {
+	n := 0
+	for e := c.list.Front(); e != nil; {
+		next := e.Next()
+		if f(e.Value.(*entry)) {
+			c.remove(e)
+			n++
+		}
+		e = next
+	}
+	return n
+}
+
+func (c *lru) resize(max int) {
+	c.max = max
+	for c.max > 0 && c.list.Len() > c.max {
+		c.remove(c.list.Back())
+	}
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	serverHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "cache_hits_total",
+			Help:      "Total number of RPCs served from the cache on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+
+	serverMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "cache_misses_total",
+			Help:      "Total number of cacheable RPCs missing the cache on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+
+	clientHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "client",
+			Name:      "cache_hits_total",
+			Help:      "Total number of RPCs served from the cache on the client.",
+		}, []string{"grpc_target", "grpc_method"})
+
+	clientMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "client",
+			Name:      "cache_misses_total",
+			Help:      "Total number of cacheable RPCs missing the cache on the client.",
+		}, []string{"grpc_target", "grpc_method"})
+)
+
+func init() {
+	prometheus.MustRegister(serverHits, serverMisses, clientHits, clientMisses)
+}
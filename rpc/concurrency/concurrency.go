@@ -0,0 +1,147 @@
+// Package concurrency limits the in-flight requests of the grpc server, so that some
+// requests fail fast instead of all slowing down when it is saturated. The limit is
+// static, or adapted to the observed latency by AIMD or gradient. Requests have a
+// priority from the metadata, the low priority ones are shed first and the queued
+// ones are admitted by priority.
+package concurrency
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rpc/internal/match"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultPriorityKey is the default metadata key of the priority
+const DefaultPriorityKey = "x-priority"
+
+// Limiter holds the rules & the limits
+type Limiter struct {
+	lock     sync.Mutex
+	conf     Config
+	patterns []string
+	limits   map[string]*limit
+}
+
+// New return a Limiter with the config
+func New(conf Config) (*Limiter, error) {
+	l := &Limiter{}
+	if err := l.Update(conf); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update replaces the rules, the limits are reset
+func (l *Limiter) Update(conf Config) error {
+	if err := conf.validate(); err != nil {
+		return err
+	}
+	if conf.PriorityKey == "" {
+		conf.PriorityKey = DefaultPriorityKey
+	}
+
+	patterns := make([]string, len(conf.Rules))
+	for i, r := range conf.Rules {
+		patterns[i] = r.Method
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.conf = conf
+	l.patterns = patterns
+	l.limits = make(map[string]*limit)
+	return nil
+}
+
+// Config returns the current config
+func (l *Limiter) Config() Config {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.conf
+}
+
+func (l *Limiter) limit(fullMethod string) *limit {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	i := match.Best(l.patterns, fullMethod)
+	if i < 0 {
+		return nil
+	}
+	rule := l.conf.Rules[i]
+
+	name := fullMethod
+	if rule.Shared {
+		name = rule.Method
+	}
+	key := strconv.Itoa(i) + "|" + name
+
+	lim, ok := l.limits[key]
+	if !ok {
+		lim = newLimit(name, rule)
+		l.limits[key] = lim
+	}
+	return lim
+}
+
+// Priority returns the priority of the call from the metadata
+func (l *Limiter) Priority(ctx context.Context) Priority {
+	key := l.Config().PriorityKey
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[key]) > 0 {
+		return ParsePriority(md[key][0])
+	}
+	return PriorityNormal
+}
+
+// Acquire takes a slot for the call, done must be called with the error of the call when it finishes
+func (l *Limiter) Acquire(ctx context.Context, fullMethod string) (done func(error), err error) {
+	lim := l.limit(fullMethod)
+	if lim == nil {
+		return func(error) {}, nil
+	}
+
+	priority := l.Priority(ctx)
+	if err := lim.acquire(ctx, priority); err != nil {
+		service, method := match.Split(fullMethod)
+		shedCounter.WithLabelValues(service, method, priority.String()).Inc()
+		log.CtxDebugf(ctx, "shed %s of %s priority: %v", fullMethod, priority, err)
+		return nil, err
+	}
+
+	start := time.Now()
+	return func(err error) { lim.release(time.Since(start), err) }, nil
+}
+
+// UnaryServerInterceptor limits the in-flight unary calls
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
+		done, err := l.Acquire(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		// released on panic too
+		defer func() { done(err) }()
+
+		return handler(ctx, request)
+	}
+}
+
+// StreamServerInterceptor limits the in-flight streams
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		done, err := l.Acquire(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer func() { done(err) }()
+
+		return handler(srv, stream)
+	}
+}
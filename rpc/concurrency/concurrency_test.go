@@ -0,0 +1,101 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+
+	"gomicro/rpc/internal/config"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func withPriority(p string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(DefaultPriorityKey, p))
+}
+
+func TestStatic(t *testing.T) {
+	l, err := New(Config{Rules: []Rule{{Method: "*", Shared: true, Limit: 5}}})
+	if err != nil {
+		t.Fatalf("new error: %v", err)
+	}
+
+	var dones []func(error)
+	for i := 0; i < 4; i++ {
+		done, err := l.Acquire(context.Background(), "/pb.HelloService/NormalHello")
+		if err != nil {
+			t.Fatalf("acquire %d error: %v", i, err)
+		}
+		dones = append(dones, done)
+	}
+
+	// low priority may use 80% of the limit only
+	if _, err := l.Acquire(withPriority("low"), "/pb.HelloService/NormalHello"); grpc.Code(err) != codes.ResourceExhausted {
+		t.Errorf("low priority error, get=%v", err)
+	}
+	done, err := l.Acquire(withPriority("high"), "/pb.HelloService/ErrorHello")
+	if err != nil {
+		t.Errorf("high priority error, get=%v", err)
+	}
+	if _, err := l.Acquire(context.Background(), "/pb.HelloService/NormalHello"); grpc.Code(err) != codes.ResourceExhausted {
+		t.Errorf("limit error, get=%v", err)
+	}
+
+	done(nil)
+	if _, err := l.Acquire(context.Background(), "/pb.HelloService/NormalHello"); err != nil {
+		t.Errorf("release error, get=%v", err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	l, _ := New(Config{Rules: []Rule{{Method: "*", Limit: 1, QueueSize: 1, QueueTimeout: config.Duration(time.Second)}}})
+	method := "/pb.HelloService/NormalHello"
+
+	done, _ := l.Acquire(context.Background(), method)
+
+	lowErr := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(withPriority("low"), method)
+		lowErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// the queued low priority request gives way to the high one
+	highErr := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(withPriority("high"), method)
+		highErr <- err
+	}()
+	if err := <-lowErr; grpc.Code(err) != codes.Unavailable {
+		t.Errorf("shed error, get=%v", err)
+	}
+
+	done(nil)
+	if err := <-highErr; err != nil {
+		t.Errorf("queue error, get=%v", err)
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	l, _ := New(Config{Rules: []Rule{{Method: "*", Mode: ModeAIMD, Limit: 10}}})
+	method := "/pb.HelloService/NormalHello"
+	lim := l.limit(method)
+
+	done, _ := l.Acquire(context.Background(), method)
+	done(grpc.Errorf(codes.DeadlineExceeded, "timeout"))
+	if lim.limit != 9 {
+		t.Errorf("decrease error, get=%v", lim.limit)
+	}
+
+	var dones []func(error)
+	for i := 0; i < 5; i++ {
+		done, _ := l.Acquire(context.Background(), method)
+		dones = append(dones, done)
+	}
+	dones[0](nil)
+	if lim.limit != 10 {
+		t.Errorf("increase error, get=%v", lim.limit)
+	}
+}
@@ -0,0 +1,120 @@
+package concurrency
+
+import (
+	"fmt"
+	"time"
+
+	"gomicro/rpc/internal/config"
+)
+
+// modes of the limit
+const (
+	// ModeStatic keeps the limit
+	ModeStatic = "static"
+	// ModeAIMD increases the limit by 1 on success, and multiplies it by Backoff on overload
+	ModeAIMD = "aimd"
+	// ModeGradient moves the limit by the ratio of the minimum latency to the current
+	ModeGradient = "gradient"
+)
+
+// Rule limits the in-flight requests of the methods matching Method
+type Rule struct {
+	// Method is "/pb.HelloService/NormalHello", "/pb.HelloService/*" or "*"
+	Method string `json:"method"`
+	// Shared shares one limit among all methods matching the rule, e.g. a global
+	// limit with "*", otherwise one limit per method
+	Shared bool `json:"shared"`
+	// Mode is static, aimd or gradient, static if empty
+	Mode string `json:"mode"`
+	// Limit is the static limit, or the initial limit of the adaptive modes
+	Limit int `json:"limit"`
+	// MinLimit & MaxLimit bound the adaptive limit, default 1 & 1000
+	MinLimit int `json:"min_limit"`
+	MaxLimit int `json:"max_limit"`
+
+	// QueueSize is the number of requests waiting for a slot, the higher priority first.
+	// Requests over the limit are rejected at once if 0
+	QueueSize int `json:"queue_size"`
+	// QueueTimeout is the longest wait in the queue, till the deadline if 0
+	QueueTimeout config.Duration `json:"queue_timeout"`
+	// LowShare is the share of the limit the low priority requests may use, default 0.8,
+	// so they are shed first
+	LowShare float64 `json:"low_share"`
+
+	// Latency above it is overload in aimd mode, only the overload errors count if 0
+	Latency config.Duration `json:"latency"`
+	// Backoff is the ratio to decrease the limit in aimd mode, default 0.9
+	Backoff float64 `json:"backoff"`
+	// Tolerance is the latency ratio to the minimum tolerated in gradient mode, default 2
+	Tolerance float64 `json:"tolerance"`
+	// Smoothing of the limit changes in gradient mode, default 0.2
+	Smoothing float64 `json:"smoothing"`
+	// MinRTTWindow is the period to reset the minimum latency in gradient mode, default 30s
+	MinRTTWindow config.Duration `json:"min_rtt_window"`
+}
+
+// Config of the concurrency limiter, the most specific rule of a method is applied
+type Config struct {
+	Rules []Rule `json:"rules"`
+	// PriorityKey is the metadata key of the priority, DefaultPriorityKey if empty
+	PriorityKey string `json:"priority_key"`
+}
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	if err := config.Load("concurrency", path, &conf); err != nil {
+		return conf, err
+	}
+	return conf, conf.validate()
+}
+
+func (c *Config) validate() error {
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.Method == "" {
+			return fmt.Errorf("concurrency: rule without method")
+		}
+		switch r.Mode {
+		case "", ModeStatic, ModeAIMD, ModeGradient:
+		default:
+			return fmt.Errorf("concurrency: unknown mode '%s' of '%s'", r.Mode, r.Method)
+		}
+		if r.Limit <= 0 {
+			return fmt.Errorf("concurrency: limit of '%s' must be positive", r.Method)
+		}
+		if r.QueueSize < 0 {
+			return fmt.Errorf("concurrency: negative queue size of '%s'", r.Method)
+		}
+	}
+	return nil
+}
+
+// withDefaults fills the zero fields
+func (r Rule) withDefaults() Rule {
+	if r.Mode == "" {
+		r.Mode = ModeStatic
+	}
+	if r.MinLimit <= 0 {
+		r.MinLimit = 1
+	}
+	if r.MaxLimit <= 0 {
+		r.MaxLimit = 1000
+	}
+	if r.LowShare <= 0 || r.LowShare > 1 {
+		r.LowShare = 0.8
+	}
+	if r.Backoff <= 0 || r.Backoff >= 1 {
+		r.Backoff = 0.9
+	}
+	if r.Tolerance < 1 {
+		r.Tolerance = 2
+	}
+	if r.Smoothing <= 0 || r.Smoothing > 1 {
+		r.Smoothing = 0.2
+	}
+	if r.MinRTTWindow <= 0 {
+		r.MinRTTWindow = config.Duration(30 * time.Second)
+	}
+	return r
+}
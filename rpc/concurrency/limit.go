@@ -0,0 +1,259 @@
+package concurrency
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Priority of a request, the lower value the higher priority
+type Priority int
+
+const (
+	// PriorityCritical requests are shed last
+	PriorityCritical Priority = iota
+	// PriorityHigh requests
+	PriorityHigh
+	// PriorityNormal is the default
+	PriorityNormal
+	// PriorityLow requests are shed first
+	PriorityLow
+)
+
+var priorityNames = []string{"critical", "high", "normal", "low"}
+
+func (p Priority) String() string {
+	if p >= 0 && int(p) < len(priorityNames) {
+		return priorityNames[p]
+	}
+	return "normal"
+}
+
+// ParsePriority parses "critical", "high", "normal" or "low", PriorityNormal if unknown
+func ParsePriority(s string) Priority {
+	for i, name := range priorityNames {
+		if name == s {
+			return Priority(i)
+		}
+	}
+	return PriorityNormal
+}
+
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	admitted bool
+}
+
+// limit of the in-flight requests of a method, or the methods sharing a rule
+type limit struct {
+	name string
+	rule Rule
+
+	lock     sync.Mutex
+	limit    float64
+	inflight int
+	queue    []*waiter
+	seq      uint64
+
+	// gradient mode
+	minRTT      time.Duration
+	minRTTReset time.Time
+}
+
+func newLimit(name string, rule Rule) *limit {
+	l := &limit{name: name, rule: rule.withDefaults(), limit: float64(rule.Limit)}
+	l.report()
+	return l
+}
+
+// threshold is the in-flight requests the priority may reach
+func (l *limit) threshold(p Priority) int {
+	n := int(l.limit)
+	if p == PriorityLow {
+		n = int(l.limit * l.rule.LowShare)
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// acquire takes a slot, waiting in the queue if it is enabled.
+// The error is ResourceExhausted if rejected at once, Unavailable if shed from the queue.
+func (l *limit) acquire(ctx context.Context, p Priority) error {
+	l.lock.Lock()
+	if l.inflight < l.threshold(p) && len(l.queue) == 0 {
+		l.inflight++
+		l.report()
+		l.lock.Unlock()
+		return nil
+	}
+
+	if len(l.queue) >= l.rule.QueueSize {
+		// the lowest priority request in the queue gives way to a higher one
+		worst := l.worst()
+		if worst < 0 || l.queue[worst].priority <= p {
+			l.lock.Unlock()
+			return grpc.Errorf(codes.ResourceExhausted, "concurrency limit %d exceeded", int(l.limit))
+		}
+		evicted := l.queue[worst]
+		l.remove(worst)
+		close(evicted.ready)
+	}
+
+	w := &waiter{priority: p, seq: l.seq, ready: make(chan struct{})}
+	l.seq++
+	l.queue = append(l.queue, w)
+	l.dispatch()
+	l.report()
+	l.lock.Unlock()
+
+	var timeout <-chan time.Time
+	if l.rule.QueueTimeout > 0 {
+		timer := time.NewTimer(time.Duration(l.rule.QueueTimeout))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-w.ready:
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if w.admitted {
+		return nil
+	}
+	for i, q := range l.queue {
+		if q == w {
+			l.remove(i)
+			break
+		}
+	}
+	l.report()
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return grpc.Errorf(codes.DeadlineExceeded, "deadline exceeded waiting for concurrency limit")
+	case context.Canceled:
+		return grpc.Errorf(codes.Canceled, "canceled waiting for concurrency limit")
+	}
+	return grpc.Errorf(codes.Unavailable, "overloaded, shed from the queue")
+}
+
+// release frees the slot, and adapts the limit by the latency & error of the call
+func (l *limit) release(rtt time.Duration, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.inflight--
+	switch l.rule.Mode {
+	case ModeAIMD:
+		l.aimd(rtt, err)
+	case ModeGradient:
+		l.gradient(rtt, err)
+	}
+	l.dispatch()
+	l.report()
+}
+
+func overloaded(err error) bool {
+	switch grpc.Code(err) {
+	case codes.DeadlineExceeded, codes.ResourceExhausted, codes.Unavailable:
+		return true
+	}
+	return false
+}
+
+func (l *limit) aimd(rtt time.Duration, err error) {
+	switch {
+	case overloaded(err), l.rule.Latency > 0 && rtt > time.Duration(l.rule.Latency):
+		l.setLimit(l.limit * l.rule.Backoff)
+	case float64(l.inflight+1) >= l.limit/2:
+		// only grows when the limit is used
+		l.setLimit(l.limit + 1)
+	}
+}
+
+func (l *limit) gradient(rtt time.Duration, err error) {
+	if err != nil && !overloaded(err) {
+		return
+	}
+
+	now := time.Now()
+	if l.minRTT == 0 || rtt < l.minRTT || now.After(l.minRTTReset) {
+		if l.minRTT == 0 || now.After(l.minRTTReset) {
+			l.minRTTReset = now.Add(time.Duration(l.rule.MinRTTWindow))
+		}
+		l.minRTT = rtt
+	}
+	if rtt <= 0 {
+		return
+	}
+
+	gradient := float64(l.minRTT) * l.rule.Tolerance / float64(rtt)
+	gradient = math.Max(0.5, math.Min(1, gradient))
+	if overloaded(err) {
+		gradient = 0.5
+	}
+
+	// the square root of the limit is the allowed queueing
+	target := l.limit*gradient + math.Sqrt(l.limit)
+	l.setLimit(l.limit*(1-l.rule.Smoothing) + target*l.rule.Smoothing)
+}
+
+func (l *limit) setLimit(v float64) {
+	l.limit = math.Max(float64(l.rule.MinLimit), math.Min(float64(l.rule.MaxLimit), v))
+}
+
+// dispatch admits the waiting requests in the order of priority & arrival
+func (l *limit) dispatch() {
+	for len(l.queue) > 0 {
+		best := l.best()
+		w := l.queue[best]
+		if l.inflight >= l.threshold(w.priority) {
+			return
+		}
+		l.remove(best)
+		l.inflight++
+		w.admitted = true
+		close(w.ready)
+	}
+}
+
+func (l *limit) best() int {
+	best := 0
+	for i, w := range l.queue {
+		if w.priority < l.queue[best].priority {
+			best = i
+		}
+	}
+	return best
+}
+
+func (l *limit) worst() int {
+	worst := -1
+	for i, w := range l.queue {
+		if worst < 0 || w.priority >= l.queue[worst].priority {
+			worst = i
+		}
+	}
+	return worst
+}
+
+func (l *limit) remove(i int) {
+	l.queue = append(l.queue[:i], l.queue[i+1:]...)
+}
+
+func (l *limit) report() {
+	limitGauge.WithLabelValues(l.name).Set(math.Floor(l.limit))
+	inflightGauge.WithLabelValues(l.name).Set(float64(l.inflight))
+	queuedGauge.WithLabelValues(l.name).Set(float64(len(l.queue)))
+}
@@ -0,0 +1,43 @@
+package concurrency
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	limitGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "concurrency_limit",
+			Help:      "Current concurrency limit, the method or the pattern of the shared rule is the limiter.",
+		}, []string{"limiter"})
+
+	inflightGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "concurrency_inflight",
+			Help:      "Current in-flight RPCs counted by the concurrency limiter.",
+		}, []string{"limiter"})
+
+	queuedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "concurrency_queued",
+			Help:      "Current RPCs waiting for the concurrency limit.",
+		}, []string{"limiter"})
+
+	shedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "shed_total",
+			Help:      "Total number of RPCs shed by the concurrency limiter.",
+		}, []string{"grpc_service", "grpc_method", "priority"})
+)
+
+func init() {
+	prometheus.MustRegister(limitGauge, inflightGauge, queuedGauge, shedCounter)
+}
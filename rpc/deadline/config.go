@@ -0,0 +1,29 @@
+package deadline
+
+import (
+	"gomicro/rpc/internal/config"
+)
+
+// Rule is the timeouts of the methods matching Method
+type Rule struct {
+	// Method is "/pb.HelloService/NormalHello", "/pb.HelloService/*" or "*"
+	Method string `json:"method"`
+	// Default is the timeout applied when the caller set no deadline
+	Default config.Duration `json:"default"`
+	// Max caps the deadline set by the caller
+	Max config.Duration `json:"max"`
+	// Slow calls longer than it are logged at Warn level
+	Slow config.Duration `json:"slow"`
+}
+
+// Config of the server deadlines, the most specific rule of a method is applied
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	err := config.Load("deadline", path, &conf)
+	return conf, err
+}
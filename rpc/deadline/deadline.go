@@ -0,0 +1,132 @@
+// Package deadline applies default & maximum timeouts to the incoming grpc calls,
+// and shortens the deadline propagated to the outgoing calls by a safety margin.
+package deadline
+
+import (
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rpc"
+	"gomicro/rpc/internal/match"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Enforcer applies the deadline rules on the server
+type Enforcer struct {
+	lock     sync.RWMutex
+	conf     Config
+	patterns []string
+}
+
+// New return an Enforcer with the config
+func New(conf Config) *Enforcer {
+	e := &Enforcer{}
+	e.Update(conf)
+	return e
+}
+
+// Update replaces the rules at runtime
+func (e *Enforcer) Update(conf Config) {
+	patterns := make([]string, len(conf.Rules))
+	for i, r := range conf.Rules {
+		patterns[i] = r.Method
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.conf = conf
+	e.patterns = patterns
+}
+
+func (e *Enforcer) rule(fullMethod string) (Rule, bool) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	if i := match.Best(e.patterns, fullMethod); i >= 0 {
+		return e.conf.Rules[i], true
+	}
+	return Rule{}, false
+}
+
+// apply returns the context with the deadline of the rule
+func apply(ctx context.Context, rule Rule) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	switch {
+	case !ok && rule.Default > 0:
+		return context.WithTimeout(ctx, time.Duration(rule.Default))
+	case !ok && rule.Max > 0, ok && rule.Max > 0 && time.Until(deadline) > time.Duration(rule.Max):
+		return context.WithTimeout(ctx, time.Duration(rule.Max))
+	}
+	return ctx, func() {}
+}
+
+// UnaryServerInterceptor applies the timeouts to unary calls
+func (e *Enforcer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule, ok := e.rule(info.FullMethod)
+		if !ok {
+			return handler(ctx, request)
+		}
+
+		ctx, cancel := apply(ctx, rule)
+		defer cancel()
+
+		start := time.Now()
+		response, err := handler(ctx, request)
+		report(ctx, info.FullMethod, rule, time.Since(start), err)
+		return response, err
+	}
+}
+
+// StreamServerInterceptor applies the timeouts to stream calls
+func (e *Enforcer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule, ok := e.rule(info.FullMethod)
+		if !ok {
+			return handler(srv, stream)
+		}
+
+		ctx, cancel := apply(stream.Context(), rule)
+		defer cancel()
+
+		start := time.Now()
+		err := handler(srv, rpc.WrapServerStream(stream, ctx))
+		report(ctx, info.FullMethod, rule, time.Since(start), err)
+		return err
+	}
+}
+
+func report(ctx context.Context, fullMethod string, rule Rule, cost time.Duration, err error) {
+	service, method := match.Split(fullMethod)
+
+	if rule.Slow > 0 && cost >= time.Duration(rule.Slow) {
+		slowCounter.WithLabelValues(service, method).Inc()
+		log.CtxWarnf(ctx, "slow call %s, cost=%v, threshold=%v", fullMethod, cost, time.Duration(rule.Slow))
+	}
+	if grpc.Code(err) == codes.DeadlineExceeded || ctx.Err() == context.DeadlineExceeded {
+		exceededCounter.WithLabelValues(service, method).Inc()
+	}
+}
+
+// UnaryClientInterceptor subtracts the margin from the deadline propagated to the downstream,
+// so the caller still has time to handle the result. Calls without time left fail at once.
+func UnaryClientInterceptor(margin time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		deadline, ok := ctx.Deadline()
+		if !ok || margin <= 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		if time.Until(deadline) <= margin {
+			return grpc.Errorf(codes.DeadlineExceeded, "no time left to call %s", method)
+		}
+
+		ctx, cancel := context.WithDeadline(ctx, deadline.Add(-margin))
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
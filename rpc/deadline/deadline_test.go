@@ -0,0 +1,94 @@
+package deadline
+
+import (
+	"testing"
+	"time"
+
+	"gomicro/rpc/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name   string
+		caller time.Duration // 0 for no deadline
+		rule   Rule
+		want   time.Duration // 0 for no deadline
+	}{
+		{"default", 0, Rule{Default: config.Duration(time.Second)}, time.Second},
+		{"max without deadline", 0, Rule{Max: config.Duration(2 * time.Second)}, 2 * time.Second},
+		{"max caps", time.Minute, Rule{Default: config.Duration(time.Second), Max: config.Duration(2 * time.Second)}, 2 * time.Second},
+		{"shorter kept", 500 * time.Millisecond, Rule{Default: config.Duration(time.Second), Max: config.Duration(2 * time.Second)}, 500 * time.Millisecond},
+		{"no rule", 0, Rule{}, 0},
+	}
+	for _, c := range cases {
+		ctx := context.Background()
+		if c.caller > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.caller)
+			defer cancel()
+		}
+
+		ctx, cancel := apply(ctx, c.rule)
+		deadline, ok := ctx.Deadline()
+		cancel()
+		if c.want == 0 && ok || c.want > 0 && (!ok || time.Until(deadline) > c.want || time.Until(deadline) < c.want-100*time.Millisecond) {
+			t.Errorf("%s error, get=%v %v", c.name, time.Until(deadline), ok)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	e := New(Config{Rules: []Rule{{Method: "/pb.HelloService/*", Default: config.Duration(20 * time.Millisecond), Slow: config.Duration(10 * time.Millisecond)}}})
+	interceptor := e.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.HelloService/NormalHello"}
+
+	exceeded, slow := counterValue(exceededCounter.WithLabelValues("pb.HelloService", "NormalHello")), counterValue(slowCounter.WithLabelValues("pb.HelloService", "NormalHello"))
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, grpc.Errorf(codes.DeadlineExceeded, "timeout")
+	})
+	if grpc.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("default deadline error, get=%v", err)
+	}
+	if delta := counterValue(exceededCounter.WithLabelValues("pb.HelloService", "NormalHello")) - exceeded; delta != 1 {
+		t.Errorf("exceeded metric error, get=%v", delta)
+	}
+	if delta := counterValue(slowCounter.WithLabelValues("pb.HelloService", "NormalHello")) - slow; delta != 1 {
+		t.Errorf("slow metric error, get=%v", delta)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	interceptor := UnaryClientInterceptor(100 * time.Millisecond)
+
+	var left time.Duration
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		deadline, _ := ctx.Deadline()
+		left = time.Until(deadline)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := interceptor(ctx, "/pb.HelloService/NormalHello", nil, nil, nil, invoker); err != nil || left > 900*time.Millisecond || left < 800*time.Millisecond {
+		t.Errorf("margin error, get=%v %v", left, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := interceptor(ctx, "/pb.HelloService/NormalHello", nil, nil, nil, invoker); grpc.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("no time left error, get=%v", err)
+	}
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	c.Write(&m)
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,27 @@
+package deadline
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	exceededCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "deadline_exceeded_total",
+			Help:      "Total number of RPCs exceeding the deadline on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+
+	slowCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "slow_total",
+			Help:      "Total number of RPCs exceeding the slow threshold on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+)
+
+func init() {
+	prometheus.MustRegister(exceededCounter, slowCounter)
+}
@@ -0,0 +1,155 @@
+// Package errors is the typed application errors of grpc services.
+// An Error carries a code, a machine-readable reason, metadata and a user-facing
+// message. The server interceptors convert the returned errors to status with
+// errdetails.ErrorInfo/RetryInfo/LocalizedMessage, and FromError decodes them back
+// on the client. The text of internal errors is logged but never sent to callers.
+package errors
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// Domain of the ErrorInfo, e.g. the service name
+	Domain = ""
+	// Locale of the LocalizedMessage
+	Locale = "en-US"
+)
+
+// Reasons of the errors converted from non application errors
+const (
+	ReasonCanceled         = "CANCELED"
+	ReasonDeadlineExceeded = "DEADLINE_EXCEEDED"
+	ReasonInternal         = "INTERNAL"
+)
+
+// Error is a typed application error
+type Error struct {
+	// Code of the grpc status
+	Code codes.Code
+	// Reason is machine-readable in UPPER_SNAKE_CASE, e.g. "USER_NOT_FOUND"
+	Reason string
+	// Message is user-facing
+	Message string
+	// Metadata of the error, e.g. {"user_id": "42"}
+	Metadata map[string]string
+	// RetryAfter tells the client when to retry, no RetryInfo if 0
+	RetryAfter time.Duration
+	// Domain overrides the package Domain if not empty
+	Domain string
+
+	// cause is internal, it is logged but never sent
+	cause error
+}
+
+// New returns an Error
+func New(code codes.Code, reason, message string) *Error {
+	return &Error{Code: code, Reason: reason, Message: message}
+}
+
+// Newf returns an Error with a formatted message
+func Newf(code codes.Code, reason, format string, v ...interface{}) *Error {
+	return New(code, reason, fmt.Sprintf(format, v...))
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s %s: %s: %v", e.Code, e.Reason, e.Message, e.cause)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Code, e.Reason, e.Message)
+}
+
+// Cause returns the internal error
+func (e *Error) Cause() error {
+	return e.cause
+}
+
+// Unwrap returns the internal error
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target has the same code & reason
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Reason == e.Reason
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	c.Metadata = make(map[string]string, len(e.Metadata))
+	for k, v := range e.Metadata {
+		c.Metadata[k] = v
+	}
+	return &c
+}
+
+// WithMetadata returns a copy of e with the metadata k=v
+func (e *Error) WithMetadata(k, v string) *Error {
+	c := e.clone()
+	c.Metadata[k] = v
+	return c
+}
+
+// WithCause returns a copy of e with the internal error
+func (e *Error) WithCause(err error) *Error {
+	c := e.clone()
+	c.cause = err
+	return c
+}
+
+// WithRetryAfter returns a copy of e with the retry delay
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	c := e.clone()
+	c.RetryAfter = d
+	return c
+}
+
+// GRPCStatus converts e to status, the internal error is left out
+func (e *Error) GRPCStatus() *status.Status {
+	domain := e.Domain
+	if domain == "" {
+		domain = Domain
+	}
+
+	st := status.New(e.Code, e.Message)
+	details := []proto.Message{
+		&errdetails.ErrorInfo{Reason: e.Reason, Domain: domain, Metadata: e.Metadata},
+		&errdetails.LocalizedMessage{Locale: Locale, Message: e.Message},
+	}
+	if e.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(e.RetryAfter)})
+	}
+
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// As finds the first Error in the chain of err, following Cause() & Unwrap()
+func As(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+
+		switch x := err.(type) {
+		case interface{ Cause() error }:
+			err = x.Cause()
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
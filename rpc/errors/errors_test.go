@@ -0,0 +1,61 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRoundTrip(t *testing.T) {
+	sent := New(codes.NotFound, "USER_NOT_FOUND", "user not found").
+		WithMetadata("user_id", "42").
+		WithRetryAfter(3 * time.Second).
+		WithCause(fmt.Errorf("sql: no rows in result set"))
+
+	err := ToStatus(sent).Err()
+	if strings.Contains(err.Error(), "sql") {
+		t.Errorf("internal error leaked, get=%v", err)
+	}
+
+	got := FromError(err)
+	if got.Code != codes.NotFound || got.Reason != "USER_NOT_FOUND" || got.Message != "user not found" ||
+		got.Metadata["user_id"] != "42" || got.RetryAfter != 3*time.Second {
+		t.Errorf("decode error, get=%+v", got)
+	}
+	if !got.Is(sent) {
+		t.Errorf("Is error, get=%v, want=%v", got, sent)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.HelloService/NormalHello"}
+	cases := []struct {
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{nil, codes.OK, ""},
+		{context.Canceled, codes.Canceled, ReasonCanceled},
+		{context.DeadlineExceeded, codes.DeadlineExceeded, ReasonDeadlineExceeded},
+		{fmt.Errorf("query users: %w", context.DeadlineExceeded), codes.DeadlineExceeded, ReasonDeadlineExceeded},
+		{fmt.Errorf("dial tcp 10.0.0.1:3306: connection refused"), codes.Internal, ReasonInternal},
+		{grpc.Errorf(codes.NotFound, "not found"), codes.NotFound, ""},
+	}
+
+	for _, c := range cases {
+		_, err := UnaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, c.err
+		})
+		if grpc.Code(err) != c.code || Reason(err) != c.reason {
+			t.Errorf("convert %v error, get=%v %q, want=%v %q", c.err, grpc.Code(err), Reason(err), c.code, c.reason)
+		}
+		if err != nil && strings.Contains(err.Error(), "10.0.0.1") {
+			t.Errorf("internal error leaked, get=%v", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package errors
+
+import (
+	stderrors "errors"
+
+	"gomicro/log"
+
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ToStatus converts err to status:
+// Error to its status, status errors as they are, context.Canceled/DeadlineExceeded,
+// wrapped or not, to Canceled/DeadlineExceeded, and the others to Internal without the error text
+func ToStatus(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	if e, ok := As(err); ok {
+		return e.GRPCStatus()
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	switch {
+	case stderrors.Is(err, context.Canceled):
+		return New(codes.Canceled, ReasonCanceled, "request canceled").GRPCStatus()
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return New(codes.DeadlineExceeded, ReasonDeadlineExceeded, "deadline exceeded").GRPCStatus()
+	}
+	return New(codes.Internal, ReasonInternal, "internal error").GRPCStatus()
+}
+
+// convert err to status error, logging the internal error text
+func convert(ctx context.Context, fullMethod string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st := ToStatus(err)
+	if e, ok := As(err); ok {
+		if e.cause != nil {
+			log.CtxWarnf(ctx, "%s failed: %v", fullMethod, err)
+		}
+	} else if _, ok := status.FromError(err); !ok {
+		log.CtxErrorf(ctx, "%s failed with internal error: %v", fullMethod, err)
+	}
+	return st.Err()
+}
+
+// UnaryServerInterceptor converts the errors returned by the handler to status,
+// install it by rpc.WithErrors so that the logs & the metrics see the converted codes
+func UnaryServerInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	response, err := handler(ctx, request)
+	return response, convert(ctx, info.FullMethod, err)
+}
+
+// StreamServerInterceptor converts the errors returned by the handler to status
+func StreamServerInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return convert(stream.Context(), info.FullMethod, handler(srv, stream))
+}
+
+// FromError decodes the error returned by a grpc call to Error, it is nil if err is nil.
+// Errors without ErrorInfo get an empty Reason.
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := As(err); ok {
+		return e
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.New(codes.Unknown, err.Error())
+	}
+
+	e := &Error{Code: st.Code(), Message: st.Message()}
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			e.Reason = d.Reason
+			e.Domain = d.Domain
+			e.Metadata = d.Metadata
+		case *errdetails.LocalizedMessage:
+			e.Message = d.Message
+		case *errdetails.RetryInfo:
+			if delay, err := ptypes.Duration(d.RetryDelay); err == nil {
+				e.RetryAfter = delay
+			}
+		}
+	}
+	return e
+}
+
+// Reason returns the reason of err, "" if it has none
+func Reason(err error) string {
+	if e := FromError(err); e != nil {
+		return e.Reason
+	}
+	return ""
+}
@@ -5,20 +5,26 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"time"
 
 	"gomicro/log"
 	"gomicro/rpc"
+	"gomicro/rpc/admin"
+	"gomicro/rpc/errors"
 	"gomicro/rpc/examples/pb"
+	"gomicro/rpc/fault"
+	"gomicro/rpc/gateway"
+	"gomicro/rpc/health"
 
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
-	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 )
 
 var (
-	port = flag.Int("port", 1701, "listening port")
+	port      = flag.Int("port", 1701, "listening port")
+	adminAddr = flag.String("admin", ":1702", "admin http address")
+	httpAddr  = flag.String("http", ":1703", "json gateway address")
 )
 
 func main() {
@@ -29,12 +35,45 @@ func main() {
 		panic(err)
 	}
 
-	log.CtxPrintf(nil, "starting hello service at %d", *port)
-	s := rpc.NewServer()
+	log.Printf("starting hello service at %d", *port)
+	h := health.New(health.Options{})
+	h.AddService("pb.HelloService")
+	h.Start()
+
+	// faults are injected in the builds with -tags chaos, PUT /fault on the admin to enable
+	injector, _ := fault.New(fault.Config{})
+
+	s := rpc.NewServer(
+		rpc.WithHealth(h),
+		rpc.WithReflection(),
+		rpc.WithErrors(),
+		rpc.WithUnaryInterceptors(injector.UnaryServerInterceptor()),
+		rpc.WithStreamInterceptors(injector.StreamServerInterceptor()),
+	)
 	pb.RegisterHelloServiceServer(s, &HelloServer{})
 	grpc_prometheus.Register(s)
-	http.Handle("/metrics", prometheus.Handler())
-	s.Serve(listener)
+
+	a := admin.New(admin.Options{Addr: *adminAddr, Server: s, Channelz: true})
+	a.Handle("/fault", "fault injection rules", injector)
+	if err := a.Start(); err != nil {
+		panic(err)
+	}
+
+	// POST /pb.HelloService/NormalHello {"greeting": "world"}
+	g, err := gateway.New(s, gateway.Options{})
+	if err != nil {
+		panic(err)
+	}
+	web := &http.Server{Addr: *httpAddr, Handler: g}
+	go web.ListenAndServe()
+
+	// serve till SIGINT/SIGTERM, then drain and stop gracefully
+	err = rpc.Run(context.Background(), s, listener, rpc.RunOptions{
+		PreStop:    []func(context.Context) error{h.Shutdown, web.Shutdown},
+		DrainDelay: time.Second,
+		PostStop:   []func(context.Context) error{a.Shutdown},
+	})
+	log.Printf("hello service stopped: %v", err)
 }
 
 // HelloServer 创建对象，实现服务
@@ -56,5 +95,5 @@ func (HelloServer) PanicHello(ctx context.Context, r *pb.HelloRequest) (*pb.Hell
 // ErrorHello 实现服务
 func (HelloServer) ErrorHello(ctx context.Context, r *pb.HelloRequest) (*pb.HelloResponse, error) {
 	log.CtxInfof(ctx, "error hello")
-	return nil, grpc.Errorf(codes.Canceled, "just try to error")
+	return nil, errors.New(codes.Canceled, "JUST_TRY", "just try to error")
 }
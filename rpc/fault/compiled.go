@@ -0,0 +1,8 @@
+//go:build !chaos
+// +build !chaos
+
+package fault
+
+// compiled is false in the production builds, the faults are injected only
+// in the builds with -tags chaos
+var compiled = false
@@ -0,0 +1,7 @@
+//go:build chaos
+// +build chaos
+
+package fault
+
+// compiled is true in the builds with -tags chaos
+var compiled = true
@@ -0,0 +1,87 @@
+package fault
+
+import (
+	"fmt"
+	"strings"
+
+	"gomicro/rpc/internal/config"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Rule injects the fault into the calls it matches
+type Rule struct {
+	// Method is "/pb.HelloService/NormalHello", "/pb.HelloService/*" or "*"
+	Method string `json:"method"`
+	// Target is the service name of the client calls, all if empty or "*", ignored on the server
+	Target string `json:"target"`
+	// Metadata the call must have, a value "*" matches any value of the key
+	Metadata map[string]string `json:"metadata"`
+	// Percentage of the matching calls to inject, 0-100, 100 if unset
+	Percentage *float64 `json:"percentage,omitempty"`
+	// Disabled keeps the rule but injects nothing
+	Disabled bool `json:"disabled"`
+
+	// Delay the call before the handler runs
+	Delay config.Duration `json:"delay"`
+	// Code fails the call with the status code, e.g. "UNAVAILABLE", without running the handler,
+	// it is the code of DropResponse or AbortAfter instead if any of them is set
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// DropResponse runs the handler then fails the call, with Code or UNAVAILABLE,
+	// ignored by the client streams
+	DropResponse bool `json:"drop_response"`
+	// AbortAfter fails the stream with Code or ABORTED after the messages are sent by the server,
+	// or received by the client, ignored by unary calls
+	AbortAfter int `json:"abort_after"`
+
+	code codes.Code
+}
+
+// Config of the fault injection, the matching rules of a call are rolled by their percentage
+// in order, the first rolled one is applied
+type Config struct {
+	// Enabled switches all the rules
+	Enabled bool   `json:"enabled"`
+	Server  []Rule `json:"server"`
+	Client  []Rule `json:"client"`
+}
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	err := config.Load("fault", path, &conf)
+	return conf, err
+}
+
+// parseCode parses "UNAVAILABLE", "Unavailable" or "14"
+func parseCode(s string) (codes.Code, error) {
+	name := strings.Replace(s, "_", "", -1)
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.EqualFold(c.String(), name) || fmt.Sprint(uint32(c)) == s {
+			return c, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown code %q", s)
+}
+
+// validate parses the codes of the rules
+func (conf *Config) validate() error {
+	for _, rules := range [][]Rule{conf.Server, conf.Client} {
+		for i := range rules {
+			r := &rules[i]
+			if r.Percentage != nil && (*r.Percentage < 0 || *r.Percentage > 100) {
+				return fmt.Errorf("fault: rule %s: percentage %v is out of 0-100", r.Method, *r.Percentage)
+			}
+			if r.Code == "" {
+				continue
+			}
+			code, err := parseCode(r.Code)
+			if err != nil {
+				return fmt.Errorf("fault: rule %s: %v", r.Method, err)
+			}
+			r.code = code
+		}
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+// Package fault injects latency & errors into the grpc calls for chaos testing, on the server
+// or on the client, without changing the handlers. The rules are updated at runtime from a
+// config file or the admin api, see Injector.ServeHTTP.
+//
+// The faults are injected only in the builds with -tags chaos, the production builds
+// keep the rules but never inject.
+package fault
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rpc/internal/match"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// Compiled reports whether the faults are injected by this build, see -tags chaos
+func Compiled() bool {
+	return compiled
+}
+
+// Injector injects the faults of the rules
+type Injector struct {
+	lock sync.RWMutex
+	conf Config
+}
+
+// New returns an Injector with the config
+func New(conf Config) (*Injector, error) {
+	i := &Injector{}
+	if err := i.Update(conf); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Update replaces the rules at runtime
+func (i *Injector) Update(conf Config) error {
+	if err := conf.validate(); err != nil {
+		return err
+	}
+	if conf.Enabled && !compiled {
+		log.Warnf("fault: rules are enabled but not injected, build with -tags chaos to inject")
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.conf = conf
+	return nil
+}
+
+// Config returns the current config
+func (i *Injector) Config() Config {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.conf
+}
+
+// find returns the first rule matching the call with its percentage rolled,
+// a rule not rolled falls through to the next ones
+func (i *Injector) find(client bool, target, fullMethod string, md metadata.MD) (Rule, bool) {
+	if !compiled {
+		return Rule{}, false
+	}
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	if !i.conf.Enabled {
+		return Rule{}, false
+	}
+
+	rules := i.conf.Server
+	if client {
+		rules = i.conf.Client
+	}
+	for _, r := range rules {
+		if r.Disabled || !match.Method(r.Method, fullMethod) || !matchMetadata(r.Metadata, md) {
+			continue
+		}
+		if client && r.Target != "" && r.Target != "*" && r.Target != target {
+			continue
+		}
+		if r.Percentage == nil || rand.Float64()*100 < *r.Percentage {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func matchMetadata(want map[string]string, md metadata.MD) bool {
+	for k, v := range want {
+		values := md[k]
+		found := len(values) > 0 && v == "*"
+		for _, value := range values {
+			found = found || value == v
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// err is the injected error of the rule, with code def if the rule has no code
+func (r *Rule) err(def codes.Code) error {
+	code := def
+	if r.Code != "" {
+		code = r.code
+	}
+	message := r.Message
+	if message == "" {
+		message = "injected fault"
+	}
+	return grpc.Errorf(code, "%s", message)
+}
+
+// before delays the call and returns the error to fail it at once
+func (r *Rule) before(ctx context.Context, report func(fault string)) error {
+	if r.Delay > 0 {
+		report("delay")
+		timer := time.NewTimer(time.Duration(r.Delay))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return grpc.Errorf(codes.DeadlineExceeded, "deadline exceeded in injected delay")
+			}
+			return grpc.Errorf(codes.Canceled, "canceled in injected delay")
+		}
+	}
+
+	if r.Code != "" && !r.DropResponse && r.AbortAfter <= 0 {
+		report("code")
+		return r.err(codes.Unknown)
+	}
+	return nil
+}
+
+// UnaryServerInterceptor injects the server rules into unary calls
+func (i *Injector) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		r, ok := i.find(false, "", info.FullMethod, md)
+		if !ok {
+			return handler(ctx, request)
+		}
+		report := serverReporter(info.FullMethod)
+
+		if err := r.before(ctx, report); err != nil {
+			return nil, err
+		}
+		response, err := handler(ctx, request)
+		if r.DropResponse {
+			report("drop")
+			return nil, r.err(codes.Unavailable)
+		}
+		return response, err
+	}
+}
+
+// StreamServerInterceptor injects the server rules into stream calls
+func (i *Injector) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		r, ok := i.find(false, "", info.FullMethod, md)
+		if !ok {
+			return handler(srv, stream)
+		}
+		report := serverReporter(info.FullMethod)
+
+		if err := r.before(stream.Context(), report); err != nil {
+			return err
+		}
+		if r.AbortAfter > 0 {
+			stream = &abortServerStream{ServerStream: stream, rule: r, report: report}
+		}
+		err := handler(srv, stream)
+		if s, ok := stream.(*abortServerStream); ok && s.aborted {
+			return r.err(codes.Aborted)
+		}
+		if r.DropResponse {
+			report("drop")
+			return r.err(codes.Unavailable)
+		}
+		return err
+	}
+}
+
+func serverReporter(fullMethod string) func(fault string) {
+	service, method := match.Split(fullMethod)
+	return func(fault string) {
+		serverInjected.WithLabelValues(service, method, fault).Inc()
+	}
+}
+
+// abortServerStream fails the stream after the rule.AbortAfter messages are sent
+type abortServerStream struct {
+	grpc.ServerStream
+	rule    Rule
+	report  func(fault string)
+	sent    int
+	aborted bool
+}
+
+func (s *abortServerStream) SendMsg(m interface{}) error {
+	if s.sent >= s.rule.AbortAfter {
+		if !s.aborted {
+			s.aborted = true
+			s.report("abort")
+		}
+		return s.rule.err(codes.Aborted)
+	}
+	s.sent++
+	return s.ServerStream.SendMsg(m)
+}
+
+// UnaryClientInterceptor returns the interceptor injecting the client rules into the calls of the service,
+// it matches rc.InterceptorBuilder, so it can be registered by rc.Use(injector.UnaryClientInterceptor).
+func (i *Injector) UnaryClientInterceptor(serviceName string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		r, ok := i.find(true, serviceName, method, md)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		report := clientReporter(serviceName, method)
+
+		if err := r.before(ctx, report); err != nil {
+			return err
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if r.DropResponse {
+			report("drop")
+			return r.err(codes.Unavailable)
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor returns the interceptor injecting the client rules into the streams of the service
+func (i *Injector) StreamClientInterceptor(serviceName string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		r, ok := i.find(true, serviceName, method, md)
+		if !ok {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		report := clientReporter(serviceName, method)
+
+		if err := r.before(ctx, report); err != nil {
+			return nil, err
+		}
+		if r.AbortAfter <= 0 {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &abortClientStream{ClientStream: stream, rule: r, report: report, cancel: cancel}, nil
+	}
+}
+
+func clientReporter(serviceName, fullMethod string) func(fault string) {
+	_, method := match.Split(fullMethod)
+	return func(fault string) {
+		clientInjected.WithLabelValues(serviceName, method, fault).Inc()
+	}
+}
+
+// abortClientStream cancels the stream after the rule.AbortAfter messages are received
+type abortClientStream struct {
+	grpc.ClientStream
+	rule     Rule
+	report   func(fault string)
+	cancel   context.CancelFunc
+	received int
+}
+
+func (s *abortClientStream) RecvMsg(m interface{}) error {
+	if s.received >= s.rule.AbortAfter {
+		if s.cancel != nil {
+			s.cancel()
+			s.cancel = nil
+			s.report("abort")
+		}
+		return s.rule.err(codes.Aborted)
+	}
+
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.cancel()
+		return err
+	}
+	s.received++
+	return nil
+}
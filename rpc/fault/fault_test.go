@@ -0,0 +1,212 @@
+package fault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gomicro/rpc/internal/config"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	defer func(c bool) { compiled = c }(compiled)
+
+	i, err := New(Config{Enabled: true, Server: []Rule{
+		{Method: "/pb.HelloService/Fail", Metadata: map[string]string{"x-chaos": "*"}, Code: "RESOURCE_EXHAUSTED"},
+		{Method: "/pb.HelloService/Drop", DropResponse: true},
+		{Method: "/pb.HelloService/*", Delay: config.Duration(20 * time.Millisecond)},
+	}})
+	if err != nil {
+		t.Fatalf("new error, get=%v", err)
+	}
+	interceptor := i.UnaryServerInterceptor()
+
+	var calls int
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return "ok", nil
+	}
+	call := func(method string, md metadata.MD) (time.Duration, error) {
+		start := time.Now()
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return time.Since(start), err
+	}
+
+	// nothing is injected if not compiled
+	compiled = false
+	if _, err := call("/pb.HelloService/Drop", nil); err != nil {
+		t.Errorf("not compiled error, get=%v", err)
+	}
+
+	compiled = true
+	if _, err := call("/pb.HelloService/Fail", metadata.Pairs("x-chaos", "1")); grpc.Code(err) != codes.ResourceExhausted || calls != 1 {
+		t.Errorf("code error, get=%v, %d calls", err, calls)
+	}
+	if cost, err := call("/pb.HelloService/Fail", nil); err != nil || cost < 20*time.Millisecond {
+		t.Errorf("delay error, get=%v %v", cost, err)
+	}
+	if _, err := call("/pb.HelloService/Drop", nil); grpc.Code(err) != codes.Unavailable || calls != 3 {
+		t.Errorf("drop error, get=%v, %d calls", err, calls)
+	}
+
+	if _, err := New(Config{Server: []Rule{{Method: "*", Code: "NO_SUCH_CODE"}}}); err == nil {
+		t.Errorf("invalid code error, get=nil")
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	defer func(c bool) { compiled = c }(compiled)
+	compiled = true
+
+	never := 0.0
+	i, err := New(Config{Enabled: true, Server: []Rule{
+		{Method: "*", Code: "UNAVAILABLE", Percentage: &never},
+		{Method: "*", Code: "INTERNAL"},
+	}})
+	if err != nil {
+		t.Fatalf("new error, get=%v", err)
+	}
+	// the rule of 0% is never injected, the call falls through to the next rule
+	for n := 0; n < 10; n++ {
+		_, err := i.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pb.HelloService/SayHello"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return "ok", nil
+			})
+		if grpc.Code(err) != codes.Internal {
+			t.Fatalf("percentage error, get=%v", err)
+		}
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	defer func(c bool) { compiled = c }(compiled)
+	compiled = true
+
+	i, err := New(Config{Enabled: true, Client: []Rule{
+		{Method: "/pb.HelloService/Fail", Target: "hello_service", Code: "UNAVAILABLE"},
+		{Method: "/pb.HelloService/Drop", DropResponse: true, Code: "DATA_LOSS"},
+	}})
+	if err != nil {
+		t.Fatalf("new error, get=%v", err)
+	}
+
+	var calls int
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return nil
+	}
+	call := func(serviceName, method string) error {
+		return i.UnaryClientInterceptor(serviceName)(context.Background(), method, nil, nil, nil, invoker)
+	}
+
+	if err := call("hello_service", "/pb.HelloService/Fail"); grpc.Code(err) != codes.Unavailable || calls != 0 {
+		t.Errorf("code error, get=%v, %d calls", err, calls)
+	}
+	// the rule of another target is not applied
+	if err := call("world_service", "/pb.HelloService/Fail"); err != nil || calls != 1 {
+		t.Errorf("target error, get=%v, %d calls", err, calls)
+	}
+	if err := call("world_service", "/pb.HelloService/Drop"); grpc.Code(err) != codes.DataLoss || calls != 2 {
+		t.Errorf("drop error, get=%v, %d calls", err, calls)
+	}
+}
+
+type recvStream struct {
+	grpc.ClientStream
+	received int
+}
+
+func (s *recvStream) RecvMsg(m interface{}) error {
+	s.received++
+	return nil
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	defer func(c bool) { compiled = c }(compiled)
+	compiled = true
+
+	i, _ := New(Config{Enabled: true, Client: []Rule{{Method: "*", AbortAfter: 2}}})
+	var streamCtx context.Context
+	inner := &recvStream{}
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		streamCtx = ctx
+		return inner, nil
+	}
+
+	stream, err := i.StreamClientInterceptor("hello_service")(context.Background(), &grpc.StreamDesc{}, nil, "/pb.HelloService/Stream", streamer)
+	if err != nil {
+		t.Fatalf("stream error, get=%v", err)
+	}
+	for n := 0; n < 5; n++ {
+		if err = stream.RecvMsg(nil); err != nil {
+			break
+		}
+	}
+	if grpc.Code(err) != codes.Aborted || inner.received != 2 || streamCtx.Err() == nil {
+		t.Errorf("abort error, get=%v, %d received", err, inner.received)
+	}
+}
+
+type sendStream struct {
+	grpc.ServerStream
+	sent int
+}
+
+func (s *sendStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *sendStream) SendMsg(m interface{}) error {
+	s.sent++
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	defer func(c bool) { compiled = c }(compiled)
+	compiled = true
+
+	i, _ := New(Config{Enabled: true, Server: []Rule{{Method: "*", AbortAfter: 2}}})
+	stream := &sendStream{}
+	err := i.StreamServerInterceptor()(nil, stream, &grpc.StreamServerInfo{FullMethod: "/pb.HelloService/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			for n := 0; n < 5; n++ {
+				if err := stream.SendMsg(n); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if grpc.Code(err) != codes.Aborted || stream.sent != 2 {
+		t.Errorf("abort error, get=%v, %d sent", err, stream.sent)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	i, _ := New(Config{})
+
+	w := httptest.NewRecorder()
+	i.ServeHTTP(w, httptest.NewRequest("PUT", "/fault", strings.NewReader(`{"enabled": true, "client": [{"method": "*", "code": "UNAVAILABLE", "percentage": 10}]}`)))
+	if conf := i.Config(); w.Code != http.StatusOK || !conf.Enabled || len(conf.Client) != 1 || conf.Client[0].code != codes.Unavailable {
+		t.Errorf("put error, get=%d %+v", w.Code, conf)
+	}
+
+	w = httptest.NewRecorder()
+	i.ServeHTTP(w, httptest.NewRequest("PUT", "/fault", strings.NewReader(`{"server": [{"method": "*", "percentage": 200}]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("put invalid error, get=%d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	i.ServeHTTP(w, httptest.NewRequest("DELETE", "/fault", nil))
+	if conf := i.Config(); w.Code != http.StatusOK || conf.Enabled || len(conf.Client) != 1 {
+		t.Errorf("delete error, get=%d %+v", w.Code, conf)
+	}
+}
@@ -0,0 +1,54 @@
+package fault
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"gomicro/log"
+)
+
+// maxConfigBytes bounds the config posted to the admin api
+const maxConfigBytes = 1 << 20
+
+type status struct {
+	Compiled bool   `json:"compiled"`
+	Config   Config `json:"config"`
+}
+
+// ServeHTTP serves the rules on the admin api, registered by admin.Handle("/fault", "fault injection rules", injector).
+// GET returns the config, PUT or POST replaces it by the json body, DELETE disables the rules.
+func (i *Injector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var conf Config
+		if err := json.Unmarshal(data, &conf); err != nil {
+			http.Error(w, "parse config error: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := i.Update(conf); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("fault: rules are updated from %s, enabled=%v", r.RemoteAddr, conf.Enabled)
+	case "DELETE":
+		conf := i.Config()
+		conf.Enabled = false
+		i.Update(conf)
+		log.Printf("fault: rules are disabled from %s", r.RemoteAddr)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(status{Compiled: compiled, Config: i.Config()})
+}
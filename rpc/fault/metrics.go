@@ -0,0 +1,27 @@
+package fault
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	serverInjected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "faults_injected_total",
+			Help:      "Total number of faults injected on the server.",
+		}, []string{"grpc_service", "grpc_method", "fault"})
+
+	clientInjected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "client",
+			Name:      "faults_injected_total",
+			Help:      "Total number of faults injected on the client.",
+		}, []string{"grpc_target", "grpc_method", "fault"})
+)
+
+func init() {
+	prometheus.MustRegister(serverInjected, clientInjected)
+}
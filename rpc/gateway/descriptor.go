@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/grpc"
+)
+
+// method is a unary method exposed by the gateway
+type method struct {
+	fullMethod string
+	input      reflect.Type
+	output     reflect.Type
+}
+
+func (m *method) newInput() proto.Message {
+	return reflect.New(m.input.Elem()).Interface().(proto.Message)
+}
+
+func (m *method) newOutput() proto.Message {
+	return reflect.New(m.output.Elem()).Interface().(proto.Message)
+}
+
+// methods returns the unary methods registered on the server, keyed by full method name
+func methods(server *grpc.Server) (map[string]*method, error) {
+	all := make(map[string]*method)
+	for name, info := range server.GetServiceInfo() {
+		fd, err := fileDescriptor(info.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("gateway: service %s: %v", name, err)
+		}
+
+		service := findService(fd, name)
+		if service == nil {
+			return nil, fmt.Errorf("gateway: service %s not found in %s", name, fd.GetName())
+		}
+
+		for _, m := range service.Method {
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				continue
+			}
+
+			input := proto.MessageType(strings.TrimPrefix(m.GetInputType(), "."))
+			output := proto.MessageType(strings.TrimPrefix(m.GetOutputType(), "."))
+			if input == nil || output == nil {
+				return nil, fmt.Errorf("gateway: unknown message type of %s/%s", name, m.GetName())
+			}
+
+			fullMethod := "/" + name + "/" + m.GetName()
+			all[fullMethod] = &method{fullMethod: fullMethod, input: input, output: output}
+		}
+	}
+	return all, nil
+}
+
+// fileDescriptor decodes the Metadata of grpc.ServiceInfo, it is the gzipped
+// FileDescriptorProto in the old generated code, or the proto file name in the new
+func fileDescriptor(metadata interface{}) (*descriptor.FileDescriptorProto, error) {
+	var gz []byte
+	switch m := metadata.(type) {
+	case []byte:
+		gz = m
+	case string:
+		if gz = proto.FileDescriptor(m); gz == nil {
+			return nil, fmt.Errorf("file %s is not registered", m)
+		}
+	default:
+		return nil, fmt.Errorf("unknown metadata %T", metadata)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(b, fd); err != nil {
+		return nil, err
+	}
+	return fd, nil
+}
+
+func findService(fd *descriptor.FileDescriptorProto, name string) *descriptor.ServiceDescriptorProto {
+	for _, service := range fd.Service {
+		fullName := service.GetName()
+		if fd.GetPackage() != "" {
+			fullName = fd.GetPackage() + "." + fullName
+		}
+		if fullName == name {
+			return service
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Package gateway exposes the unary methods of a grpc server as JSON over http,
+// `POST /package.Service/Method` with the request in the body. The methods are found
+// by the registered service info without codegen, and called through an in-process
+// connection to the server, so they go through the same interceptor chain.
+package gateway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gomicro/log"
+
+	"github.com/golang/protobuf/jsonpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// DefaultMaxBodyBytes is the default limit of the request body
+const DefaultMaxBodyBytes = 4 << 20
+
+var js = &jsonpb.Marshaler{EmitDefaults: true, OrigName: true}
+
+// headers not passed to the metadata
+var skipHeaders = map[string]bool{
+	"connection":        true,
+	"content-length":    true,
+	"content-type":      true,
+	"host":              true,
+	"keep-alive":        true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"accept-encoding":   true,
+}
+
+// Options of the gateway
+type Options struct {
+	// MaxBodyBytes limits the request body, DefaultMaxBodyBytes if 0
+	MaxBodyBytes int64
+	// AllowUnknownFields of the request JSON
+	AllowUnknownFields bool
+}
+
+// Gateway is the http handler calling the grpc server
+type Gateway struct {
+	opts     Options
+	methods  map[string]*method
+	listener *bufconn.Listener
+	conn     *grpc.ClientConn
+}
+
+// New returns the gateway of the server, call it after all the services are registered
+func New(server *grpc.Server, opts Options) (*Gateway, error) {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	all, err := methods(server)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := bufconn.Listen(256 << 10)
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial("gateway", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &Gateway{opts: opts, methods: all, listener: listener, conn: conn}, nil
+}
+
+// Methods returns the exposed methods
+func (g *Gateway) Methods() []string {
+	names := make([]string, 0, len(g.methods))
+	for name := range g.methods {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Close closes the in-process connection
+func (g *Gateway) Close() error {
+	err := g.conn.Close()
+	g.listener.Close()
+	return err
+}
+
+// ServeHTTP calls the method of the path
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m, ok := g.methods[r.URL.Path]
+	if !ok {
+		writeError(w, grpc.Errorf(codes.Unimplemented, "unknown method %s", r.URL.Path))
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, g.opts.MaxBodyBytes+1))
+	if err != nil {
+		writeError(w, grpc.Errorf(codes.InvalidArgument, "read body error: %v", err))
+		return
+	}
+	if int64(len(body)) > g.opts.MaxBodyBytes {
+		writeError(w, grpc.Errorf(codes.ResourceExhausted, "body is larger than %d bytes", g.opts.MaxBodyBytes))
+		return
+	}
+
+	request := m.newInput()
+	if len(bytes.TrimSpace(body)) > 0 {
+		unmarshaler := &jsonpb.Unmarshaler{AllowUnknownFields: g.opts.AllowUnknownFields}
+		if err := unmarshaler.Unmarshal(bytes.NewReader(body), request); err != nil {
+			writeError(w, grpc.Errorf(codes.InvalidArgument, "invalid json: %v", err))
+			return
+		}
+	}
+
+	ctx := metadata.NewOutgoingContext(r.Context(), toMetadata(r))
+	var header, trailer metadata.MD
+	response := m.newOutput()
+	err = g.conn.Invoke(ctx, m.fullMethod, request, response, grpc.Header(&header), grpc.Trailer(&trailer))
+
+	for _, md := range []metadata.MD{header, trailer} {
+		for k, vs := range md {
+			if skipHeaders[k] || strings.HasSuffix(k, "-bin") {
+				continue
+			}
+			for _, v := range vs {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := js.Marshal(w, response); err != nil {
+		log.CtxErrorf(ctx, "gateway: marshal response of %s error: %v", m.fullMethod, err)
+	}
+}
+
+// toMetadata passes the http headers as metadata, decoding the "-bin" ones from base64
+func toMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for k, vs := range r.Header {
+		k = strings.ToLower(k)
+		if skipHeaders[k] || strings.HasPrefix(k, "grpc-") || strings.HasPrefix(k, ":") {
+			continue
+		}
+		for _, v := range vs {
+			if strings.HasSuffix(k, "-bin") {
+				b, err := base64.StdEncoding.DecodeString(v)
+				if err != nil {
+					continue
+				}
+				v = string(b)
+			}
+			md[k] = append(md[k], v)
+		}
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		md["x-forwarded-for"] = append(md["x-forwarded-for"], host)
+	}
+	return md
+}
@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGateway(t *testing.T) {
+	var got metadata.MD
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		got, _ = metadata.FromIncomingContext(ctx)
+		return handler(ctx, req)
+	}))
+	h := health.NewServer()
+	h.SetServingStatus("hello", pb.HealthCheckResponse_SERVING)
+	pb.RegisterHealthServer(server, h)
+	defer server.Stop()
+
+	g, err := New(server, Options{})
+	if err != nil {
+		t.Fatalf("new gateway error: %v", err)
+	}
+	defer g.Close()
+
+	cases := []struct {
+		method, path, body string
+		code               int
+		response           string
+	}{
+		{"POST", "/grpc.health.v1.Health/Check", `{"service": "hello"}`, http.StatusOK, `"status":"SERVING"`},
+		{"POST", "/grpc.health.v1.Health/Check", `{"service": "unknown"}`, http.StatusNotFound, `"code":5`},
+		{"POST", "/grpc.health.v1.Health/Check", `{"service": 1}`, http.StatusBadRequest, `"code":3`},
+		{"POST", "/grpc.health.v1.Health/Watch", `{}`, http.StatusNotImplemented, `"code":12`},
+		{"GET", "/grpc.health.v1.Health/Check", ``, http.StatusMethodNotAllowed, ``},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		r.Header.Set("X-Request-Id", "42")
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.response) {
+			t.Errorf("%s %s error, get=%d %s, want=%d %s", c.method, c.body, w.Code, w.Body, c.code, c.response)
+		}
+	}
+
+	if ids := got["x-request-id"]; len(ids) != 1 || ids[0] != "42" {
+		t.Errorf("metadata error, get=%v", got)
+	}
+}
@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HTTPStatus maps the grpc code to the http status code
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError writes the status as JSON, i.e. google.rpc.Status with the details
+func writeError(w http.ResponseWriter, err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		switch err {
+		case context.Canceled:
+			st = status.New(codes.Canceled, err.Error())
+		case context.DeadlineExceeded:
+			st = status.New(codes.DeadlineExceeded, err.Error())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := js.Marshal(&buf, st.Proto()); err != nil {
+		// the details of unknown types can't be marshalled
+		buf.Reset()
+		js.Marshal(&buf, status.New(st.Code(), st.Message()).Proto())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(HTTPStatus(st.Code()))
+	w.Write(buf.Bytes())
+}
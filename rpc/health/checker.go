@@ -0,0 +1,49 @@
+package health
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Checker checks a dependency of the service, returns nil if it is healthy
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Pinger is implemented by *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingChecker checks a database by pinging it
+func PingChecker(db Pinger) Checker {
+	return CheckerFunc(db.PingContext)
+}
+
+// ConnChecker checks the state of a grpc connection, got by conn every time
+// since the connection may be established later, see rc.Checker.
+func ConnChecker(conn func() *grpc.ClientConn) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		c := conn()
+		if c == nil {
+			return fmt.Errorf("connection is not established")
+		}
+
+		switch state := c.GetState(); state {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return fmt.Errorf("connection is %s", state)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,314 @@
+// Package health implements grpc.health.v1.Health, the serving status of every
+// service comes from the Checkers run on an interval, and can be overridden
+// manually for maintenance. The aggregate status is the status of service "".
+package health
+
+import (
+	"sync"
+	"time"
+
+	"gomicro/log"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Status of a service
+type Status = healthpb.HealthCheckResponse_ServingStatus
+
+const (
+	// Serving means the service is healthy
+	Serving = healthpb.HealthCheckResponse_SERVING
+	// NotServing means the service is unhealthy
+	NotServing = healthpb.HealthCheckResponse_NOT_SERVING
+)
+
+// Options of the health Server
+type Options struct {
+	Interval time.Duration // interval of running the checkers, 10s if zero
+	Timeout  time.Duration // timeout of one checker, 5s if zero
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Server is the health service
+type Server struct {
+	opts Options
+
+	lock      sync.RWMutex
+	checkers  map[string][]namedChecker   // service -> checkers
+	failures  map[string]map[string]error // service -> checker -> last error
+	overrides map[string]Status           // service -> manual status
+	statuses  map[string]Status           // service -> current status
+	watchers  map[string]map[chan Status]struct{}
+	shutdown  bool
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// New return a health Server, the overall service "" is serving
+func New(opts Options) *Server {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	return &Server{
+		opts:      opts,
+		checkers:  make(map[string][]namedChecker),
+		failures:  make(map[string]map[string]error),
+		overrides: make(map[string]Status),
+		statuses:  map[string]Status{"": Serving},
+		watchers:  make(map[string]map[chan Status]struct{}),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Register registers the health service to the grpc server, see rpc.WithHealth
+func (s *Server) Register(server *grpc.Server) {
+	healthpb.RegisterHealthServer(server, s)
+}
+
+// AddService makes the service known, serving if no checker fails
+func (s *Server) AddService(service string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.statuses[service]; !ok {
+		s.statuses[service] = Serving
+		s.update()
+	}
+}
+
+// AddChecker adds a named checker to the service, "" for the whole server
+func (s *Server) AddChecker(service, name string, checker Checker) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.checkers[service] = append(s.checkers[service], namedChecker{name: name, checker: checker})
+	if _, ok := s.statuses[service]; !ok {
+		s.statuses[service] = Serving
+	}
+}
+
+// SetOverride forces the status of the service, e.g. NotServing for maintenance
+func (s *Server) SetOverride(service string, status Status) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.overrides[service] = status
+	if _, ok := s.statuses[service]; !ok {
+		s.statuses[service] = status
+	}
+	s.update()
+}
+
+// ClearOverride lets the checkers decide the status of the service again
+func (s *Server) ClearOverride(service string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.overrides, service)
+	s.update()
+}
+
+// Shutdown sets all services NOT_SERVING for ever, it is a pre-stop hook of rpc.Run
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	s.shutdown = true
+	s.update()
+	s.lock.Unlock()
+
+	s.Stop()
+	return nil
+}
+
+// Status returns the status of the service, false if the service is unknown
+func (s *Server) Status(service string) (Status, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	status, ok := s.statuses[service]
+	return status, ok
+}
+
+// Serving reports the aggregate status, it can be used as the HealthCheck of the naming registrars
+func (s *Server) Serving() bool {
+	status, _ := s.Status("")
+	return status == Serving
+}
+
+// Failures returns the last errors of the failing checkers, keyed by "service/checker"
+func (s *Server) Failures() map[string]error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	failures := make(map[string]error)
+	for service, errs := range s.failures {
+		for name, err := range errs {
+			failures[service+"/"+name] = err
+		}
+	}
+	return failures
+}
+
+// Start runs the checkers at once and then on the interval
+func (s *Server) Start() {
+	go func() {
+		ticker := time.NewTicker(s.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.runCheckers()
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops running the checkers
+func (s *Server) Stop() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+func (s *Server) runCheckers() {
+	s.lock.RLock()
+	all := make(map[string][]namedChecker, len(s.checkers))
+	for service, checkers := range s.checkers {
+		all[service] = checkers
+	}
+	s.lock.RUnlock()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures = make(map[string]map[string]error)
+	)
+	for service, checkers := range all {
+		for _, c := range checkers {
+			wg.Add(1)
+			go func(service string, c namedChecker) {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+				defer cancel()
+				if err := c.checker.Check(ctx); err != nil {
+					mu.Lock()
+					if failures[service] == nil {
+						failures[service] = make(map[string]error)
+					}
+					failures[service][c.name] = err
+					mu.Unlock()
+				}
+			}(service, c)
+		}
+	}
+	wg.Wait()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for service, errs := range failures {
+		for name, err := range errs {
+			if _, failed := s.failures[service][name]; !failed {
+				log.Warnf("health: checker '%s/%s' failed: %v", service, name, err)
+			}
+		}
+	}
+	for service, errs := range s.failures {
+		for name := range errs {
+			if _, failed := failures[service][name]; !failed {
+				log.Printf("health: checker '%s/%s' recovered", service, name)
+			}
+		}
+	}
+	s.failures = failures
+	s.update()
+}
+
+// update recomputes the statuses and notifies the watchers, must hold the lock
+func (s *Server) update() {
+	for service := range s.statuses {
+		status := Serving
+		switch override, ok := s.overrides[service]; {
+		case s.shutdown:
+			status = NotServing
+		case ok:
+			status = override
+		case len(s.failures[service]) > 0, service == "" && len(s.failures) > 0:
+			// the server is unhealthy if any dependency is
+			status = NotServing
+		}
+
+		if s.statuses[service] != status {
+			log.Printf("health: service '%s' status changed from %s to %s", service, s.statuses[service], status)
+			s.statuses[service] = status
+		}
+		for ch := range s.watchers[service] {
+			// drop the stale status not received yet, keep the latest one
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- status
+		}
+	}
+}
+
+// Check implements grpc.health.v1.Health
+func (s *Server) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	status, ok := s.Status(req.Service)
+	if !ok {
+		return nil, grpc.Errorf(codes.NotFound, "unknown service %s", req.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: status}, nil
+}
+
+// Watch implements grpc.health.v1.Health
+func (s *Server) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ch := make(chan Status, 1)
+
+	s.lock.Lock()
+	status, ok := s.statuses[req.Service]
+	if !ok {
+		status = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+	}
+	ch <- status
+	if s.watchers[req.Service] == nil {
+		s.watchers[req.Service] = make(map[chan Status]struct{})
+	}
+	s.watchers[req.Service][ch] = struct{}{}
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.watchers[req.Service], ch)
+		s.lock.Unlock()
+	}()
+
+	last := Status(-1)
+	for {
+		select {
+		case status := <-ch:
+			if status == last {
+				continue
+			}
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: status}); err != nil {
+				return err
+			}
+			last = status
+		case <-stream.Context().Done():
+			return grpc.Errorf(codes.Canceled, "stream has ended")
+		}
+	}
+}
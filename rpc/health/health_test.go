@@ -0,0 +1,58 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestServer(t *testing.T) {
+	s := New(Options{Interval: time.Hour})
+
+	var dbErr error
+	s.AddChecker("pb.HelloService", "db", CheckerFunc(func(ctx context.Context) error { return dbErr }))
+
+	check := func(service string) Status {
+		resp, err := s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("check '%s' error: %v", service, err)
+		}
+		return resp.Status
+	}
+
+	s.runCheckers()
+	if check("pb.HelloService") != Serving || !s.Serving() {
+		t.Errorf("service should be serving")
+	}
+
+	dbErr = errors.New("connection refused")
+	s.runCheckers()
+	if check("pb.HelloService") != NotServing || s.Serving() {
+		t.Errorf("service & server should not be serving when db fails")
+	}
+	if len(s.Failures()) != 1 {
+		t.Errorf("failures error, get=%v", s.Failures())
+	}
+
+	s.SetOverride("pb.HelloService", Serving)
+	if check("pb.HelloService") != Serving {
+		t.Errorf("override should win")
+	}
+	s.ClearOverride("pb.HelloService")
+
+	dbErr = nil
+	s.runCheckers()
+	s.Shutdown(context.Background())
+	if check("") != NotServing || check("pb.HelloService") != NotServing {
+		t.Errorf("all services should not be serving after shutdown")
+	}
+
+	if _, err := s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"}); grpc.Code(err) != codes.NotFound {
+		t.Errorf("unknown service should be not found, get=%v", err)
+	}
+}
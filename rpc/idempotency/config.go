@@ -0,0 +1,34 @@
+package idempotency
+
+import (
+	"time"
+
+	"gomicro/rpc/internal/config"
+)
+
+// Rule is the idempotency of the methods matching Method
+type Rule struct {
+	// Method is "/pb.PaymentService/Pay", "/pb.PaymentService/*" or "*"
+	Method string `json:"method"`
+	// Window is how long the result of a key is kept for the duplicates, disabled if 0
+	Window config.Duration `json:"window"`
+}
+
+// Config of the idempotency, the most specific rule of a method is applied,
+// methods matching no rule ignore the idempotency key
+type Config struct {
+	Rules []Rule `json:"rules"`
+	// InFlightTimeout bounds how long a key is claimed by a running call,
+	// so that a crashed instance does not block the key, DefaultInFlightTimeout if 0
+	InFlightTimeout config.Duration `json:"in_flight_timeout"`
+}
+
+// DefaultInFlightTimeout is the default claim of a running call
+const DefaultInFlightTimeout = config.Duration(time.Minute)
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	err := config.Load("idempotency", path, &conf)
+	return conf, err
+}
@@ -0,0 +1,134 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// EtcdStore keeps the records in etcd, shared by the instances of a service
+type EtcdStore struct {
+	keyAPI etcd.KeysAPI
+	prefix string
+}
+
+// NewEtcdStore returns the store in etcd under prefix, e.g. "/idempotency/payment_service",
+// target is the dial address of etcd, e.g. "http://127.0.0.1:2379,http://127.0.0.1:12379"
+func NewEtcdStore(target, prefix string) (*EtcdStore, error) {
+	client, err := etcd.New(etcd.Config{Endpoints: strings.Split(target, ",")})
+	if err != nil {
+		return nil, fmt.Errorf("idempotency: create etcd client error: %v", err)
+	}
+	return &EtcdStore{keyAPI: etcd.NewKeysAPI(client), prefix: strings.TrimSuffix(prefix, "/")}, nil
+}
+
+// path of the key, the idempotency key is escaped as it comes from the callers
+func (s *EtcdStore) path(key string) string {
+	return s.prefix + "/" + strings.Replace(key, "/", "%2F", -1)
+}
+
+func decode(node *etcd.Node) (*Record, error) {
+	rec := &Record{}
+	if err := json.Unmarshal([]byte(node.Value), rec); err != nil {
+		return nil, fmt.Errorf("idempotency: decode record %s error: %v", node.Key, err)
+	}
+	return rec, nil
+}
+
+// Begin implements Store
+func (s *EtcdStore) Begin(ctx context.Context, key string, rec *Record, ttl time.Duration) (*Record, error) {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.keyAPI.Set(ctx, s.path(key), string(value), &etcd.SetOptions{PrevExist: etcd.PrevNoExist, TTL: ttl})
+	if err == nil {
+		return nil, nil
+	}
+	if e, ok := err.(etcd.Error); !ok || e.Code != etcd.ErrorCodeNodeExist {
+		return nil, err
+	}
+
+	resp, err := s.keyAPI.Get(ctx, s.path(key), nil)
+	if etcd.IsKeyNotFound(err) {
+		// expired in between, claim it again
+		return s.Begin(ctx, key, rec, ttl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(resp.Node)
+}
+
+// lost tells if the compare of a claimed operation failed
+func lost(err error) bool {
+	e, ok := err.(etcd.Error)
+	return ok && (e.Code == etcd.ErrorCodeKeyNotFound || e.Code == etcd.ErrorCodeTestFailed)
+}
+
+// Complete implements Store, the record is swapped only if the value is still the claim of its token
+func (s *EtcdStore) Complete(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	claim, err := json.Marshal(&Record{Method: rec.Method, Fingerprint: rec.Fingerprint, Token: rec.Token})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.keyAPI.Set(ctx, s.path(key), string(value), &etcd.SetOptions{PrevValue: string(claim), TTL: ttl})
+	if lost(err) {
+		return ErrClaimLost
+	}
+	return err
+}
+
+// Abort implements Store, the key is deleted only if the value is still the claim
+func (s *EtcdStore) Abort(ctx context.Context, key string, claim *Record) error {
+	value, err := json.Marshal(claim)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.keyAPI.Delete(ctx, s.path(key), &etcd.DeleteOptions{PrevValue: string(value)})
+	if lost(err) {
+		return nil
+	}
+	return err
+}
+
+// Wait implements Store
+func (s *EtcdStore) Wait(ctx context.Context, key string) (*Record, error) {
+	resp, err := s.keyAPI.Get(ctx, s.path(key), nil)
+	if etcd.IsKeyNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec, err := decode(resp.Node)
+	if err != nil || rec.Done {
+		return rec, err
+	}
+
+	watcher := s.keyAPI.Watcher(s.path(key), &etcd.WatcherOptions{AfterIndex: resp.Index})
+	for {
+		resp, err := watcher.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.Action {
+		case "delete", "expire", "compareAndDelete":
+			return nil, nil
+		}
+		if rec, err := decode(resp.Node); err != nil || rec.Done {
+			return rec, err
+		}
+	}
+}
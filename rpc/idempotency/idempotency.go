@@ -0,0 +1,277 @@
+// Package idempotency makes the retried mutations safe on the server. The first call
+// with an idempotency key runs the handler and stores its response or error status,
+// the duplicates within the window get the stored result instead of running again,
+// and the duplicates arriving while the first call is running wait for it.
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rpc/auth"
+	"gomicro/rpc/internal/match"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/pborman/uuid"
+	"golang.org/x/net/context"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// KeyHeader is the metadata key of the idempotency key sent by the client
+	KeyHeader = "idempotency-key"
+	// ReplayedHeader is set to "true" in the response header of the replayed calls
+	ReplayedHeader = "idempotency-replayed"
+)
+
+// storeTimeout bounds the store operations after the handler returns,
+// they are not bound to the call as the client may have gone already
+const storeTimeout = 3 * time.Second
+
+// Idempotency applies the idempotency rules on the server
+type Idempotency struct {
+	store Store
+
+	lock     sync.RWMutex
+	conf     Config
+	patterns []string
+}
+
+// New returns an Idempotency keeping the results in store
+func New(store Store, conf Config) *Idempotency {
+	i := &Idempotency{store: store}
+	i.Update(conf)
+	return i
+}
+
+// Update replaces the rules at runtime
+func (i *Idempotency) Update(conf Config) {
+	if conf.InFlightTimeout <= 0 {
+		conf.InFlightTimeout = DefaultInFlightTimeout
+	}
+	patterns := make([]string, len(conf.Rules))
+	for n, r := range conf.Rules {
+		patterns[n] = r.Method
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.conf = conf
+	i.patterns = patterns
+}
+
+func (i *Idempotency) rule(fullMethod string) (Rule, time.Duration, bool) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	if n := match.Best(i.patterns, fullMethod); n >= 0 && i.conf.Rules[n].Window > 0 {
+		return i.conf.Rules[n], time.Duration(i.conf.InFlightTimeout), true
+	}
+	return Rule{}, 0, false
+}
+
+// Key returns the store key of the call, the idempotency key is scoped by the method
+// and the authenticated caller, so a caller can not get the result of another
+func Key(ctx context.Context, fullMethod, key string) string {
+	subject := ""
+	if p := auth.FromContext(ctx); p != nil {
+		subject = p.Subject
+	}
+	return fullMethod + "/" + subject + "/" + key
+}
+
+// fingerprint is the hash of the deterministic marshal of the request
+func fingerprint(request interface{}) (string, bool) {
+	pb, ok := request.(proto.Message)
+	if !ok {
+		return "", false
+	}
+
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(pb); err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), true
+}
+
+// transient errors are not stored, the retries of them run the handler again
+func transient(err error) bool {
+	switch grpc.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// UnaryServerInterceptor applies the idempotency to unary calls, calls without
+// the idempotency key or to methods matching no rule run as usual.
+// The calls fail with Unavailable if the store fails, rather than risk running twice.
+func (i *Idempotency) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule, inFlight, ok := i.rule(info.FullMethod)
+		if !ok {
+			return handler(ctx, request)
+		}
+		key := firstMetadata(ctx, KeyHeader)
+		if key == "" {
+			return handler(ctx, request)
+		}
+		fp, ok := fingerprint(request)
+		if !ok {
+			return handler(ctx, request)
+		}
+
+		storeKey := Key(ctx, info.FullMethod, key)
+		for {
+			claim := &Record{Method: info.FullMethod, Fingerprint: fp, Token: uuid.New()}
+			existing, err := i.store.Begin(ctx, storeKey, claim, inFlight)
+			if err != nil {
+				log.CtxErrorf(ctx, "idempotency: begin %s error: %v", storeKey, err)
+				return nil, grpc.Errorf(codes.Unavailable, "idempotency store unavailable")
+			}
+			if existing == nil {
+				return i.run(ctx, request, storeKey, claim, rule, handler)
+			}
+
+			if existing.Method != info.FullMethod || existing.Fingerprint != fp {
+				service, method := match.Split(info.FullMethod)
+				conflictCounter.WithLabelValues(service, method).Inc()
+				return nil, grpc.Errorf(codes.FailedPrecondition, "idempotency key %s is reused with a different request", key)
+			}
+
+			if !existing.Done {
+				existing, err = i.store.Wait(ctx, storeKey)
+				if err != nil {
+					switch ctx.Err() {
+					case context.DeadlineExceeded:
+						return nil, grpc.Errorf(codes.DeadlineExceeded, "deadline exceeded waiting for the first call")
+					case context.Canceled:
+						return nil, grpc.Errorf(codes.Canceled, "canceled waiting for the first call")
+					}
+					log.CtxErrorf(ctx, "idempotency: wait %s error: %v", storeKey, err)
+					return nil, grpc.Errorf(codes.Unavailable, "idempotency store unavailable")
+				}
+				if existing == nil {
+					// the first call is aborted, claim the key again
+					continue
+				}
+			}
+
+			service, method := match.Split(info.FullMethod)
+			replayedCounter.WithLabelValues(service, method).Inc()
+			return replay(ctx, existing)
+		}
+	}
+}
+
+// run calls the handler with the key claimed, and stores the result.
+// The claim is released if the result is not stored, the handler panics included.
+func (i *Idempotency) run(ctx context.Context, request interface{}, storeKey string, claim *Record, rule Rule,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+		storeCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		defer cancel()
+		if err := i.store.Abort(storeCtx, storeKey, claim); err != nil {
+			log.CtxErrorf(ctx, "idempotency: abort %s error: %v", storeKey, err)
+		}
+	}()
+
+	response, err := handler(ctx, request)
+	if transient(err) {
+		return response, err
+	}
+
+	rec, e := record(claim, response, err)
+	if e != nil {
+		log.CtxErrorf(ctx, "idempotency: encode result of %s error: %v", storeKey, e)
+		return response, err
+	}
+
+	storeCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if e := i.store.Complete(storeCtx, storeKey, rec, time.Duration(rule.Window)); e != nil {
+		log.CtxErrorf(ctx, "idempotency: complete %s error: %v", storeKey, e)
+		return response, err
+	}
+	stored = true
+	return response, err
+}
+
+// record returns the done record of the result of the claim
+func record(claim *Record, response interface{}, err error) (*Record, error) {
+	rec := &Record{Method: claim.Method, Fingerprint: claim.Fingerprint, Token: claim.Token, Done: true}
+	if err != nil {
+		st, _ := status.FromError(err)
+		data, e := proto.Marshal(st.Proto())
+		if e != nil {
+			return nil, e
+		}
+		rec.Status = data
+		return rec, nil
+	}
+
+	pb, ok := response.(proto.Message)
+	if !ok {
+		return nil, grpc.Errorf(codes.Internal, "response %T is not a proto message", response)
+	}
+	packed, e := ptypes.MarshalAny(pb)
+	if e != nil {
+		return nil, e
+	}
+	data, e := proto.Marshal(packed)
+	if e != nil {
+		return nil, e
+	}
+	rec.Response = data
+	return rec, nil
+}
+
+// replay returns the stored result of the record
+func replay(ctx context.Context, rec *Record) (interface{}, error) {
+	grpc.SetHeader(ctx, metadata.Pairs(ReplayedHeader, "true"))
+
+	if len(rec.Status) > 0 {
+		st := &spb.Status{}
+		if err := proto.Unmarshal(rec.Status, st); err != nil {
+			return nil, grpc.Errorf(codes.Internal, "decode stored status error: %v", err)
+		}
+		return nil, status.ErrorProto(st)
+	}
+
+	packed := &any.Any{}
+	if err := proto.Unmarshal(rec.Response, packed); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "decode stored response error: %v", err)
+	}
+	var response ptypes.DynamicAny
+	if err := ptypes.UnmarshalAny(packed, &response); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "decode stored response error: %v", err)
+	}
+	return response.Message, nil
+}
+
+// helper function to get the first value of a metadata key
+func firstMetadata(ctx context.Context, key string) string {
+	meta, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := meta[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
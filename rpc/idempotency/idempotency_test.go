@@ -0,0 +1,163 @@
+package idempotency
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gomicro/rpc/internal/config"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	i := New(NewMemoryStore(), Config{Rules: []Rule{{Method: "/grpc.health.v1.Health/*", Window: config.Duration(time.Minute)}}})
+	interceptor := i.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+
+	var calls int32
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		switch req.(*pb.HealthCheckRequest).Service {
+		case "invalid":
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid service")
+		case "busy":
+			return nil, grpc.Errorf(codes.Unavailable, "busy")
+		}
+		return &pb.HealthCheckResponse{Status: pb.HealthCheckResponse_SERVING}, nil
+	}
+	call := func(key, service string) (interface{}, error) {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(KeyHeader, key))
+		}
+		return interceptor(ctx, &pb.HealthCheckRequest{Service: service}, info, handler)
+	}
+
+	// in-flight duplicates wait for the first call
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := call("k1", "a")
+			if err != nil || response.(*pb.HealthCheckResponse).Status != pb.HealthCheckResponse_SERVING {
+				t.Errorf("call error, get=%v %v", response, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("duplicate error, get=%d calls", calls)
+	}
+
+	// the key is reused with a different request
+	if _, err := call("k1", "b"); grpc.Code(err) != codes.FailedPrecondition {
+		t.Errorf("conflict error, get=%v", err)
+	}
+
+	// the error status is replayed
+	call("k2", "invalid")
+	if _, err := call("k2", "invalid"); grpc.Code(err) != codes.InvalidArgument || calls != 2 {
+		t.Errorf("replay status error, get=%v, %d calls", err, calls)
+	}
+
+	// transient errors & calls without key run again
+	call("k3", "busy")
+	call("k3", "busy")
+	call("", "a")
+	call("", "a")
+	if calls != 6 {
+		t.Errorf("run again error, get=%d calls", calls)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	if rec, err := s.Begin(ctx, "k", &Record{Fingerprint: "a"}, 50*time.Millisecond); rec != nil || err != nil {
+		t.Errorf("begin error, get=%v %v", rec, err)
+	}
+	if rec, _ := s.Begin(ctx, "k", &Record{Fingerprint: "b"}, time.Minute); rec == nil || rec.Fingerprint != "a" {
+		t.Errorf("begin claimed error, get=%v", rec)
+	}
+
+	// the claim expires
+	if rec, err := s.Wait(ctx, "k"); rec != nil || err != nil {
+		t.Errorf("wait expired error, get=%v %v", rec, err)
+	}
+
+	s.Begin(ctx, "k", &Record{}, time.Minute)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Complete(ctx, "k", &Record{Done: true, Response: []byte("ok")}, time.Minute)
+	}()
+	if rec, err := s.Wait(ctx, "k"); err != nil || rec == nil || string(rec.Response) != "ok" {
+		t.Errorf("wait error, get=%v %v", rec, err)
+	}
+
+	// done records are not aborted
+	s.Abort(ctx, "k", &Record{})
+	if rec, _ := s.Begin(ctx, "k", &Record{}, time.Minute); rec == nil || !rec.Done {
+		t.Errorf("abort error, get=%v", rec)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	// the done record expires after the window, then the key is claimed again
+	s.Begin(ctx, "k", &Record{}, time.Minute)
+	s.Complete(ctx, "k", &Record{Done: true}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if rec, err := s.Begin(ctx, "k", &Record{}, time.Minute); rec != nil || err != nil {
+		t.Errorf("begin after window error, get=%v %v", rec, err)
+	}
+
+	// the expired records are swept
+	s.Complete(ctx, "k", &Record{Done: true}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	s.lastSweep = time.Now().Add(-2 * time.Minute)
+	if rec, err := s.Wait(ctx, "other"); rec != nil || err != nil || len(s.entries) != 0 {
+		t.Errorf("sweep error, get=%v %v, %d entries", rec, err, len(s.entries))
+	}
+}
+
+func TestMemoryStoreClaimOwner(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	// the claim of a slow first call expires, and a retry claims the key again
+	s.Begin(ctx, "k", &Record{Token: "first"}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if rec, err := s.Begin(ctx, "k", &Record{Token: "retry"}, time.Minute); rec != nil || err != nil {
+		t.Errorf("begin expired error, get=%v %v", rec, err)
+	}
+
+	// the first call can not abort or complete the claim of the retry
+	s.Abort(ctx, "k", &Record{Token: "first"})
+	if err := s.Complete(ctx, "k", &Record{Token: "first", Done: true}, time.Minute); err != ErrClaimLost {
+		t.Errorf("complete not owner error, get=%v", err)
+	}
+	if rec, _ := s.Begin(ctx, "k", &Record{}, time.Minute); rec == nil || rec.Token != "retry" || rec.Done {
+		t.Errorf("claim owner error, get=%v", rec)
+	}
+
+	if err := s.Complete(ctx, "k", &Record{Token: "retry", Done: true, Response: []byte("retry")}, time.Minute); err != nil {
+		t.Errorf("complete error, get=%v", err)
+	}
+	if err := s.Complete(ctx, "k", &Record{Token: "retry", Done: true}, time.Minute); err != ErrClaimLost {
+		t.Errorf("complete twice error, get=%v", err)
+	}
+	if rec, err := s.Wait(ctx, "k"); err != nil || rec == nil || string(rec.Response) != "retry" {
+		t.Errorf("wait error, get=%v %v", rec, err)
+	}
+}
@@ -0,0 +1,27 @@
+package idempotency
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	replayedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "idempotent_replayed_total",
+			Help:      "Total number of duplicate RPCs answered with the stored result on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+
+	conflictCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "idempotent_conflicts_total",
+			Help:      "Total number of RPCs reusing an idempotency key with a different request on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+)
+
+func init() {
+	prometheus.MustRegister(replayedCounter, conflictCounter)
+}
@@ -0,0 +1,165 @@
+package idempotency
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Record of an idempotency key
+type Record struct {
+	// Method & Fingerprint of the first request, a duplicate must have the same
+	Method      string `json:"method"`
+	Fingerprint string `json:"fingerprint"`
+	// Token identifies the claim, only its owner completes or aborts it
+	Token string `json:"token"`
+	// Done is false while the first request is in flight
+	Done bool `json:"done"`
+	// Response is the marshalled Any of the response, Status the marshalled
+	// google.rpc.Status of the error, one of them is set when Done
+	Response []byte `json:"response,omitempty"`
+	Status   []byte `json:"status,omitempty"`
+}
+
+// ErrClaimLost is returned by Complete if the key is no longer claimed by the token
+// of the record, e.g. the claim expired and a duplicate claimed the key again
+var ErrClaimLost = errors.New("idempotency: claim is lost")
+
+// Store of the records
+type Store interface {
+	// Begin claims the key with the in-flight record for ttl,
+	// returns the existing record instead if the key is claimed already
+	Begin(ctx context.Context, key string, rec *Record, ttl time.Duration) (existing *Record, err error)
+	// Complete saves the done record for ttl if the key is still claimed by its token,
+	// ErrClaimLost otherwise
+	Complete(ctx context.Context, key string, rec *Record, ttl time.Duration) error
+	// Abort releases the claim, so that the duplicates run again,
+	// nothing is done if the key is no longer claimed by it
+	Abort(ctx context.Context, key string, claim *Record) error
+	// Wait blocks till the record is done, nil if it is aborted or expires
+	Wait(ctx context.Context, key string) (*Record, error)
+}
+
+// MemoryStore keeps the records in memory, for a single instance
+type MemoryStore struct {
+	lock      sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	rec     *Record
+	expires time.Time
+	done    chan struct{} // closed when the record is done, aborted, replaced or expires
+	closed  bool
+}
+
+// release wakes up the waiters, must be called with the lock of the store
+func (e *memoryEntry) release() {
+	if !e.closed {
+		e.closed = true
+		close(e.done)
+	}
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry), lastSweep: time.Now()}
+}
+
+// get returns the live entry of key, must be called with the lock
+func (s *MemoryStore) get(key string, now time.Time) *memoryEntry {
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.lastSweep = now
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+				e.release()
+			}
+		}
+	}
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if now.After(e.expires) {
+		delete(s.entries, key)
+		e.release()
+		return nil
+	}
+	return e
+}
+
+// Begin implements Store
+func (s *MemoryStore) Begin(ctx context.Context, key string, rec *Record, ttl time.Duration) (*Record, error) {
+	now := time.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e := s.get(key, now); e != nil {
+		return e.rec, nil
+	}
+	s.entries[key] = &memoryEntry{rec: rec, expires: now.Add(ttl), done: make(chan struct{})}
+	return nil, nil
+}
+
+// Complete implements Store
+func (s *MemoryStore) Complete(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	now := time.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e := s.get(key, now)
+	if e == nil || e.rec.Done || e.rec.Token != rec.Token {
+		return ErrClaimLost
+	}
+	e.release()
+	e = &memoryEntry{rec: rec, expires: now.Add(ttl), done: make(chan struct{})}
+	e.release()
+	s.entries[key] = e
+	return nil
+}
+
+// Abort implements Store
+func (s *MemoryStore) Abort(ctx context.Context, key string, claim *Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.entries[key]; ok && !e.rec.Done && e.rec.Token == claim.Token {
+		delete(s.entries, key)
+		e.release()
+	}
+	return nil
+}
+
+func (s *MemoryStore) lookup(key string) *memoryEntry {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.get(key, time.Now())
+}
+
+// Wait implements Store
+func (s *MemoryStore) Wait(ctx context.Context, key string) (*Record, error) {
+	for {
+		e := s.lookup(key)
+		if e == nil {
+			return nil, nil
+		}
+		if e.rec.Done {
+			return e.rec, nil
+		}
+
+		timer := time.NewTimer(time.Until(e.expires))
+		select {
+		case <-e.done:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
@@ -22,3 +22,60 @@ func build(interceptor grpc.UnaryServerInterceptor, handler grpc.UnaryHandler, i
 		return interceptor(ctx, request, info, handler)
 	}
 }
+
+// StreamInterceptorChain build the multi stream interceptors into one interceptor chain
+func StreamInterceptorChain(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		chain := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			chain = buildStream(interceptors[i], chain, info)
+		}
+
+		return chain(srv, stream)
+	}
+}
+
+func buildStream(interceptor grpc.StreamServerInterceptor, handler grpc.StreamHandler, info *grpc.StreamServerInfo) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		return interceptor(srv, stream, info, handler)
+	}
+}
+
+// WrappedServerStream replaces the context of a grpc.ServerStream,
+// stream interceptors use it to pass values down to the handler.
+type WrappedServerStream struct {
+	grpc.ServerStream
+	Ctx context.Context
+}
+
+// Context returns the wrapped context
+func (s *WrappedServerStream) Context() context.Context {
+	return s.Ctx
+}
+
+// WrapServerStream returns a stream whose Context() is ctx, the stream passed in is not changed,
+// so the interceptors outside still see their own context
+func WrapServerStream(stream grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	if wrapped, ok := stream.(*WrappedServerStream); ok {
+		return &WrappedServerStream{ServerStream: wrapped.ServerStream, Ctx: ctx}
+	}
+	return &WrappedServerStream{ServerStream: stream, Ctx: ctx}
+}
+
+// UnaryClientInterceptorChain build the multi client interceptors into one interceptor chain
+func UnaryClientInterceptorChain(interceptors ...grpc.UnaryClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		chain := invoker
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			chain = buildClient(interceptors[i], chain)
+		}
+
+		return chain(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func buildClient(interceptor grpc.UnaryClientInterceptor, invoker grpc.UnaryInvoker) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return interceptor(ctx, method, req, reply, cc, invoker, opts...)
+	}
+}
@@ -0,0 +1,21 @@
+package rpc
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+type key struct{}
+
+func TestWrapServerStream(t *testing.T) {
+	outer := WrapServerStream(nil, context.WithValue(context.Background(), key{}, "outer"))
+	inner := WrapServerStream(outer, context.WithValue(context.Background(), key{}, "inner"))
+
+	if v := outer.Context().Value(key{}); v != "outer" {
+		t.Errorf("outer context error, get=%v", v)
+	}
+	if v := inner.Context().Value(key{}); v != "inner" || inner.(*WrappedServerStream).ServerStream != nil {
+		t.Errorf("inner context error, get=%v", v)
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"1m30s"`), &d); err != nil || time.Duration(d) != 90*time.Second {
+		t.Errorf("unmarshal error, get=%v %v", time.Duration(d), err)
+	}
+	if err := json.Unmarshal([]byte(`5000000000`), &d); err != nil || time.Duration(d) != 5*time.Second {
+		t.Errorf("unmarshal nanoseconds error, get=%v %v", time.Duration(d), err)
+	}
+	for _, data := range []string{`"500"`, `true`} {
+		if err := json.Unmarshal([]byte(data), &d); err == nil {
+			t.Errorf("unmarshal %s error, get=nil", data)
+		}
+	}
+	if data, _ := json.Marshal(Duration(500 * time.Millisecond)); string(data) != `"500ms"` {
+		t.Errorf("marshal error, get=%s", data)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	ioutil.WriteFile(path, []byte(`{"ttl": "1s"}`), 0600)
+
+	var conf struct {
+		TTL Duration `json:"ttl"`
+	}
+	if err := Load("cache", path, &conf); err != nil || time.Duration(conf.TTL) != time.Second {
+		t.Errorf("load error, get=%+v %v", conf, err)
+	}
+	if err := Load("cache", path+".missing", &conf); err == nil || !strings.HasPrefix(err.Error(), "cache: read config error") {
+		t.Errorf("load missing error, get=%v", err)
+	}
+}
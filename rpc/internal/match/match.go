@@ -0,0 +1,69 @@
+// Package match implements the method patterns shared by the rpc interceptors.
+//
+// A pattern is one of:
+//
+//	"*"                          - every method
+//	"/pb.HelloService/*"         - every method of a service
+//	"/pb.HelloService/NormalHello" - exactly one method
+package match
+
+import (
+	"strings"
+)
+
+// Method reports whether the full grpc method name matches the pattern
+func Method(pattern, fullMethod string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(fullMethod, pattern[:len(pattern)-1])
+	default:
+		return pattern == fullMethod
+	}
+}
+
+// Any reports whether the full method name matches one of the patterns
+func Any(patterns []string, fullMethod string) bool {
+	for _, pattern := range patterns {
+		if Method(pattern, fullMethod) {
+			return true
+		}
+	}
+	return false
+}
+
+// Best returns the index of the most specific pattern matching the method, -1 if none.
+// exact method > service wildcard > "*", the first one wins on a tie.
+func Best(patterns []string, fullMethod string) int {
+	best, rank := -1, -1
+	for i, pattern := range patterns {
+		if !Method(pattern, fullMethod) {
+			continue
+		}
+		if r := specificity(pattern); r > rank {
+			best, rank = i, r
+		}
+	}
+	return best
+}
+
+// Split splits "/pb.HelloService/NormalHello" into "pb.HelloService" and "NormalHello"
+func Split(fullMethod string) (service, method string) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if pos := strings.LastIndex(name, "/"); pos >= 0 {
+		return name[:pos], name[pos+1:]
+	}
+	return "", name
+}
+
+func specificity(pattern string) int {
+	switch {
+	case pattern == "*":
+		return 0
+	case strings.HasSuffix(pattern, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
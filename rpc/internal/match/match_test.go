@@ -0,0 +1,31 @@
+package match
+
+import (
+	"testing"
+)
+
+func TestBest(t *testing.T) {
+	patterns := []string{"*", "/pb.HelloService/*", "/pb.HelloService/NormalHello"}
+
+	cases := map[string]int{
+		"/pb.HelloService/NormalHello": 2,
+		"/pb.HelloService/ErrorHello":  1,
+		"/pb.OtherService/Hello":       0,
+	}
+	for method, want := range cases {
+		if got := Best(patterns, method); got != want {
+			t.Errorf("Best(%s) error, get=%d, want=%d", method, got, want)
+		}
+	}
+
+	if got := Best(patterns[1:], "/pb.OtherService/Hello"); got != -1 {
+		t.Errorf("Best should not match, get=%d", got)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	service, method := Split("/pb.HelloService/NormalHello")
+	if service != "pb.HelloService" || method != "NormalHello" {
+		t.Errorf("Split error, get=%s %s", service, method)
+	}
+}
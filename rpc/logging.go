@@ -1,21 +1,145 @@
 package rpc
 
 import (
+	"sync"
 	"time"
 
 	"gomicro/log"
+	"gomicro/rpc/internal/match"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 )
 
+// PayloadMode decides which payloads are logged
+type PayloadMode int
+
+const (
+	// PayloadBoth logs both request & response
+	PayloadBoth PayloadMode = iota
+	// PayloadRequest logs request only
+	PayloadRequest
+	// PayloadOff logs no payload
+	PayloadOff
+)
+
+// LoggingConfig of the Logging interceptor, the zero fields take the values of DefaultLoggingConfig
+type LoggingConfig struct {
+	// Include methods to log, e.g. "/pb.HelloService/*", all methods if empty
+	Include []string
+	// Exclude methods not to log, it wins over Include, use an empty non-nil slice to exclude nothing
+	Exclude []string
+	// Payload mode
+	Payload PayloadMode
+	// MaxPayloadBytes truncates the logged payload, no limit if negative
+	MaxPayloadBytes int
+	// SuccessLevel & ErrorLevel are the log levels of finished calls, errors are never logged at Debug
+	SuccessLevel int
+	ErrorLevel   int
+	// SlowThreshold logs calls slower than it at Warn level, disabled if negative
+	SlowThreshold time.Duration
+}
+
+// DefaultLoggingConfig skips health checks, logs success at Debug and errors at Warn
+var DefaultLoggingConfig = LoggingConfig{
+	Exclude:         []string{"/grpc.health.v1.Health/*"},
+	Payload:         PayloadBoth,
+	MaxPayloadBytes: 4096,
+	SuccessLevel:    log.Ldebug,
+	ErrorLevel:      log.Lwarn,
+	SlowThreshold:   time.Second,
+}
+
+var (
+	loggingConfig = DefaultLoggingConfig
+	loggingLock   sync.RWMutex
+)
+
+// SetLoggingConfig changes the config of Logging
+func SetLoggingConfig(conf LoggingConfig) {
+	if conf.Exclude == nil {
+		conf.Exclude = DefaultLoggingConfig.Exclude
+	}
+	if conf.MaxPayloadBytes == 0 {
+		conf.MaxPayloadBytes = DefaultLoggingConfig.MaxPayloadBytes
+	}
+	if conf.ErrorLevel == log.Ldebug {
+		conf.ErrorLevel = DefaultLoggingConfig.ErrorLevel
+	}
+	if conf.SlowThreshold == 0 {
+		conf.SlowThreshold = DefaultLoggingConfig.SlowThreshold
+	}
+
+	loggingLock.Lock()
+	loggingConfig = conf
+	loggingLock.Unlock()
+}
+
+// GetLoggingConfig returns the config of Logging
+func GetLoggingConfig() LoggingConfig {
+	loggingLock.RLock()
+	defer loggingLock.RUnlock()
+	return loggingConfig
+}
+
+func (conf *LoggingConfig) enabled(fullMethod string) bool {
+	for _, pattern := range conf.Exclude {
+		if match.Method(pattern, fullMethod) {
+			return false
+		}
+	}
+	if len(conf.Include) == 0 {
+		return true
+	}
+	for _, pattern := range conf.Include {
+		if match.Method(pattern, fullMethod) {
+			return true
+		}
+	}
+	return false
+}
+
 // Logging interceptor for grpc
 func Logging(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
-	start := time.Now()
+	conf := GetLoggingConfig()
+	if !conf.enabled(info.FullMethod) {
+		return handler(ctx, request)
+	}
 
-	log.CtxInfof(ctx, "calling %s, request=%s", info.FullMethod, marshal(request))
+	start := time.Now()
 	response, err = handler(ctx, request)
-	log.CtxInfof(ctx, "finished %s, cost=%v, response=%v, err=%v", info.FullMethod, time.Since(start), response, err)
+	cost := time.Since(start)
+
+	code := grpc.Code(err)
+	level := conf.SuccessLevel
+	if code != codes.OK {
+		level = conf.ErrorLevel
+	}
+	if conf.SlowThreshold > 0 && cost >= conf.SlowThreshold && level < log.Lwarn {
+		level = log.Lwarn
+	}
+	// skip marshalling the payloads of the discarded lines
+	if level < log.GetOutputLevel() {
+		return response, err
+	}
+
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+
+	req, resp := "-", "-"
+	switch conf.Payload {
+	case PayloadBoth:
+		resp = truncate(marshal(response), conf.MaxPayloadBytes)
+		fallthrough
+	case PayloadRequest:
+		req = truncate(marshal(request), conf.MaxPayloadBytes)
+	}
 
+	log.CtxLevelf(ctx, level, "finished %s, code=%s, peer=%s, cost=%v, request=%s, response=%s, err=%v",
+		info.FullMethod, code, addr, cost, req, resp, err)
 	return response, err
 }
@@ -0,0 +1,102 @@
+package rpc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gomicro/log"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func captureLogs(t *testing.T, level int) *bytes.Buffer {
+	var buf bytes.Buffer
+	out, old := log.Writer(), log.GetOutputLevel()
+	log.SetOutput(&buf)
+	log.SetOutputLevel(level)
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		log.SetOutputLevel(old)
+		SetLoggingConfig(DefaultLoggingConfig)
+	})
+	return &buf
+}
+
+func TestLoggingLevel(t *testing.T) {
+	buf := captureLogs(t, log.Linfo)
+	SetLoggingConfig(LoggingConfig{SlowThreshold: 20 * time.Millisecond})
+	if conf := GetLoggingConfig(); conf.ErrorLevel != log.Lwarn || conf.MaxPayloadBytes != 4096 || len(conf.Exclude) != 1 {
+		t.Errorf("default fields error, get=%+v", conf)
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.HelloService/NormalHello"}
+	request := &pb.HealthCheckRequest{Service: "hello"}
+	cases := []struct {
+		err   error
+		sleep time.Duration
+		want  string
+	}{
+		{nil, 0, ""},
+		{grpc.Errorf(codes.NotFound, "not found"), 0, "[WARN]"},
+		{nil, 30 * time.Millisecond, "[WARN]"},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		Logging(context.Background(), request, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			time.Sleep(c.sleep)
+			return &pb.HealthCheckResponse{}, c.err
+		})
+		if got := buf.String(); c.want == "" && got != "" || !strings.Contains(got, c.want) {
+			t.Errorf("level of %v %v error, get=%q", c.err, c.sleep, got)
+		}
+	}
+}
+
+func TestLoggingPayload(t *testing.T) {
+	buf := captureLogs(t, log.Ldebug)
+	SetLoggingConfig(LoggingConfig{Payload: PayloadRequest, MaxPayloadBytes: 12})
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.HelloService/NormalHello"}
+	Logging(context.Background(), &pb.HealthCheckRequest{Service: "a long service name"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.HealthCheckResponse{Status: pb.HealthCheckResponse_SERVING}, nil
+	})
+	if got := buf.String(); !strings.Contains(got, `request={"service":"...(truncated, 33 bytes)`) || !strings.Contains(got, "response=-") {
+		t.Errorf("payload error, get=%q", got)
+	}
+}
+
+func TestLoggingEnabled(t *testing.T) {
+	conf := LoggingConfig{Include: []string{"/pb.HelloService/*"}, Exclude: []string{"/pb.HelloService/PanicHello"}}
+	for method, want := range map[string]bool{
+		"/pb.HelloService/NormalHello": true,
+		"/pb.HelloService/PanicHello":  false,
+		"/pb.OtherService/Hello":       false,
+	} {
+		if got := conf.enabled(method); got != want {
+			t.Errorf("enabled %s error, get=%v, want=%v", method, got, want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 0, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 2, "he...(truncated, 5 bytes)"},
+		{"héllo", 2, "h...(truncated, 6 bytes)"},
+		{"世界", 4, "世...(truncated, 6 bytes)"},
+	} {
+		if got := truncate(c.s, c.max); got != c.want {
+			t.Errorf("truncate %q %d error, get=%q, want=%q", c.s, c.max, got, c.want)
+		}
+	}
+}
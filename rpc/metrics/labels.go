@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultMaxValues is the default cardinality bound of a Label
+const DefaultMaxValues = 100
+
+// Other is the value of a label over its cardinality bound or not allowed
+const Other = "other"
+
+// Label is an extra label of the metrics, the value comes from the metadata or the context
+type Label struct {
+	// Name of the label, e.g. "tenant"
+	Name string
+	// Metadata key of the value, e.g. "x-tenant"
+	Metadata string
+	// Func returns the value from the context, used if Metadata is empty
+	Func func(ctx context.Context) string
+	// Allowed values, the others are Other, no allowlist if empty
+	Allowed []string
+	// MaxValues bounds the distinct values, the values seen after are Other, DefaultMaxValues if 0
+	MaxValues int
+}
+
+// guard limits the values of a label
+type guard struct {
+	Label
+
+	lock    sync.Mutex
+	allowed map[string]bool
+	seen    map[string]bool
+}
+
+func newGuard(l Label) *guard {
+	if l.MaxValues <= 0 {
+		l.MaxValues = DefaultMaxValues
+	}
+	g := &guard{Label: l, seen: make(map[string]bool)}
+	if len(l.Allowed) > 0 {
+		g.allowed = make(map[string]bool, len(l.Allowed))
+		for _, v := range l.Allowed {
+			g.allowed[v] = true
+		}
+	}
+	return g
+}
+
+func (g *guard) value(ctx context.Context) string {
+	v := ""
+	if g.Metadata != "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[g.Metadata]) > 0 {
+			v = md[g.Metadata][0]
+		}
+	} else if g.Func != nil {
+		v = g.Func(ctx)
+	}
+	if v == "" {
+		return "unknown"
+	}
+	if g.allowed != nil && !g.allowed[v] {
+		return Other
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if !g.seen[v] {
+		if len(g.seen) >= g.MaxValues {
+			return Other
+		}
+		g.seen[v] = true
+	}
+	return v
+}
@@ -0,0 +1,195 @@
+// Package metrics is the prometheus metrics of the grpc server: latency histograms with
+// configurable buckets, request/response size histograms and in-flight gauges, with extra
+// labels from the metadata or the context guarded against high cardinality.
+// The metrics are registered to the Registerer of the Options, e.g. a prometheus.Registry
+// of a test, and installed by rpc.WithMetrics next to the default grpc_prometheus.
+package metrics
+
+import (
+	"time"
+
+	"gomicro/rpc/internal/match"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// DefaultSizeBuckets are 64B, 256B, ... 16MB
+var DefaultSizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+
+// Options of the metrics
+type Options struct {
+	// Registerer of the metrics, prometheus.DefaultRegisterer if nil
+	Registerer prometheus.Registerer
+	// LatencyBuckets in seconds, prometheus.DefBuckets if empty
+	LatencyBuckets []float64
+	// SizeBuckets in bytes, DefaultSizeBuckets if empty
+	SizeBuckets []float64
+	// Labels are extra labels of the histograms
+	Labels []Label
+}
+
+// Metrics of the grpc server
+type Metrics struct {
+	guards       []*guard
+	latency      *prometheus.HistogramVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	inflight     *prometheus.GaugeVec
+}
+
+// New returns the Metrics registered to the Registerer
+func New(opts Options) (*Metrics, error) {
+	if opts.Registerer == nil {
+		opts.Registerer = prometheus.DefaultRegisterer
+	}
+	if len(opts.LatencyBuckets) == 0 {
+		opts.LatencyBuckets = prometheus.DefBuckets
+	}
+	if len(opts.SizeBuckets) == 0 {
+		opts.SizeBuckets = DefaultSizeBuckets
+	}
+
+	m := &Metrics{}
+	labels := []string{"grpc_type", "grpc_service", "grpc_method"}
+	for _, l := range opts.Labels {
+		m.guards = append(m.guards, newGuard(l))
+		labels = append(labels, l.Name)
+	}
+
+	m.latency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "request_duration_seconds",
+			Help:      "Histogram of the handling time of RPCs on the server.",
+			Buckets:   opts.LatencyBuckets,
+		}, append(labels, "grpc_code"))
+
+	m.requestSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "request_size_bytes",
+			Help:      "Histogram of the size of the messages received on the server.",
+			Buckets:   opts.SizeBuckets,
+		}, labels)
+
+	m.responseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "response_size_bytes",
+			Help:      "Histogram of the size of the messages sent on the server.",
+			Buckets:   opts.SizeBuckets,
+		}, labels)
+
+	m.inflight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "inflight_requests",
+			Help:      "Current number of RPCs being handled on the server.",
+		}, []string{"grpc_type", "grpc_service", "grpc_method"})
+
+	for _, c := range []prometheus.Collector{m.latency, m.requestSize, m.responseSize, m.inflight} {
+		if err := opts.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// labels returns the label values of the call
+func (m *Metrics) labels(ctx context.Context, typ, fullMethod string) []string {
+	service, method := match.Split(fullMethod)
+	values := []string{typ, service, method}
+	for _, g := range m.guards {
+		values = append(values, g.value(ctx))
+	}
+	return values
+}
+
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	}
+	return "server_stream"
+}
+
+func size(msg interface{}) float64 {
+	if pb, ok := msg.(proto.Message); ok {
+		return float64(proto.Size(pb))
+	}
+	return 0
+}
+
+// UnaryServerInterceptor records the unary calls
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
+		labels := m.labels(ctx, "unary", info.FullMethod)
+		inflight := m.inflight.WithLabelValues(labels[:3]...)
+		inflight.Inc()
+		m.requestSize.WithLabelValues(labels...).Observe(size(request))
+
+		start := time.Now()
+		defer func() {
+			inflight.Dec()
+			m.latency.WithLabelValues(append(labels, grpc.Code(err).String())...).Observe(time.Since(start).Seconds())
+		}()
+
+		response, err = handler(ctx, request)
+		if err == nil {
+			m.responseSize.WithLabelValues(labels...).Observe(size(response))
+		}
+		return response, err
+	}
+}
+
+// StreamServerInterceptor records the streams, the sizes are of every message
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		labels := m.labels(stream.Context(), streamType(info), info.FullMethod)
+		inflight := m.inflight.WithLabelValues(labels[:3]...)
+		inflight.Inc()
+
+		start := time.Now()
+		defer func() {
+			inflight.Dec()
+			m.latency.WithLabelValues(append(labels, grpc.Code(err).String())...).Observe(time.Since(start).Seconds())
+		}()
+
+		return handler(srv, &measuredStream{
+			ServerStream: stream,
+			received:     m.requestSize.WithLabelValues(labels...),
+			sent:         m.responseSize.WithLabelValues(labels...),
+		})
+	}
+}
+
+type measuredStream struct {
+	grpc.ServerStream
+	received prometheus.Observer
+	sent     prometheus.Observer
+}
+
+func (s *measuredStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Observe(size(m))
+	}
+	return err
+}
+
+func (s *measuredStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received.Observe(size(m))
+	}
+	return err
+}
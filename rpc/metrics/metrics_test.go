@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := New(Options{
+		Registerer:     registry,
+		LatencyBuckets: []float64{0.1, 1},
+		Labels:         []Label{{Name: "tenant", Metadata: "x-tenant", MaxValues: 2}},
+	})
+	if err != nil {
+		t.Fatalf("new error: %v", err)
+	}
+
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	for _, tenant := range []string{"a", "b", "c", "a"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant", tenant))
+		interceptor(ctx, &pb.HealthCheckRequest{Service: "hello"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, grpc.Errorf(codes.NotFound, "unknown service")
+		})
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather error: %v", err)
+	}
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "grpc_server_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.Metric {
+			labels := map[string]string{}
+			for _, pair := range metric.Label {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if labels["grpc_code"] != "NotFound" || labels["grpc_method"] != "Check" {
+				t.Errorf("labels error, get=%v", labels)
+			}
+			counts[labels["tenant"]] += metric.Histogram.GetSampleCount()
+		}
+	}
+
+	// "c" is over the cardinality bound
+	if counts["a"] != 2 || counts["b"] != 1 || counts[Other] != 1 {
+		t.Errorf("tenant label error, get=%v", counts)
+	}
+}
@@ -0,0 +1,48 @@
+package mirror
+
+import (
+	"time"
+
+	"gomicro/rpc/internal/config"
+)
+
+// Rule mirrors the calls of the methods matching Method
+type Rule struct {
+	// Method is "/pb.UserService/GetUser", "/pb.UserService/*" or "*"
+	Method string `json:"method"`
+	// Target is the service name of the primary calls, all if empty or "*"
+	Target string `json:"target"`
+	// Shadow is the service name to mirror to, it must be connected by rc.StartServiceConns,
+	// e.g. "user_service_canary"
+	Shadow string `json:"shadow"`
+	// Percentage of the calls to mirror, 0-100, 100 if unset
+	Percentage *float64 `json:"percentage,omitempty"`
+	// Disabled turns off the mirroring of the methods
+	Disabled bool `json:"disabled"`
+	// Timeout of the shadow calls, DefaultTimeout if 0
+	Timeout config.Duration `json:"timeout"`
+	// Compare the shadow responses with the primary ones, they are discarded if false
+	Compare bool `json:"compare"`
+	// Ignore are the response fields not to compare, see record.Diff
+	Ignore []string `json:"ignore"`
+}
+
+// Config of the mirroring, the most specific rule of a call is applied
+type Config struct {
+	Rules []Rule `json:"rules"`
+	// MaxInFlight bounds the concurrent shadow calls, the calls beyond are not mirrored, DefaultMaxInFlight if 0
+	MaxInFlight int `json:"max_in_flight"`
+}
+
+// DefaultTimeout of the shadow calls & DefaultMaxInFlight of the shadow calls
+const (
+	DefaultTimeout     = config.Duration(time.Second)
+	DefaultMaxInFlight = 100
+)
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	err := config.Load("mirror", path, &conf)
+	return conf, err
+}
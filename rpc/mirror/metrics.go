@@ -0,0 +1,35 @@
+package mirror
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	mirroredCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "client",
+			Name:      "mirrored_total",
+			Help:      "Total number of RPCs mirrored to the shadow services by the code of the shadow calls.",
+		}, []string{"grpc_target", "grpc_method", "grpc_code"})
+
+	droppedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "client",
+			Name:      "mirror_dropped_total",
+			Help:      "Total number of sampled RPCs not mirrored as the shadow is not connected or too many are in flight.",
+		}, []string{"grpc_target", "grpc_method"})
+
+	mismatchCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "client",
+			Name:      "mirror_mismatches_total",
+			Help:      "Total number of shadow responses differing from the primary ones.",
+		}, []string{"grpc_target", "grpc_method"})
+)
+
+func init() {
+	prometheus.MustRegister(mirroredCounter, droppedCounter, mismatchCounter)
+}
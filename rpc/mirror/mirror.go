@@ -0,0 +1,210 @@
+// Package mirror is the client interceptor duplicating a percentage of the calls to a
+// shadow service, e.g. the canary of a new version, without affecting the callers.
+// The shadow calls are sent asynchronously after the primary calls return, with their
+// own timeouts, and their responses are discarded or compared with the primary ones.
+//
+// The shadow calls carry the ShadowHeader metadata, so that the shadow service can skip
+// the side effects, e.g. sending emails or charging twice.
+package mirror
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rc"
+	"gomicro/rpc/internal/match"
+	"gomicro/rpc/record"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ShadowHeader is set to "true" in the metadata of the shadow calls
+const ShadowHeader = "x-shadow"
+
+var js = &jsonpb.Marshaler{OrigName: true}
+
+// Options of the Mirror
+type Options struct {
+	// Conn returns the connection to the shadow service, rc.Conn if nil
+	Conn func(serviceName string) *grpc.ClientConn
+}
+
+// Mirror duplicates the calls by the rules
+type Mirror struct {
+	opts Options
+
+	lock     sync.RWMutex
+	conf     Config
+	inFlight chan struct{}
+}
+
+// New returns a Mirror with the config
+func New(conf Config, opts Options) *Mirror {
+	if opts.Conn == nil {
+		opts.Conn = rc.Conn
+	}
+	m := &Mirror{opts: opts}
+	m.Update(conf)
+	return m
+}
+
+// Update replaces the rules at runtime, the shadow calls in flight are not affected
+func (m *Mirror) Update(conf Config) {
+	if conf.MaxInFlight <= 0 {
+		conf.MaxInFlight = DefaultMaxInFlight
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.inFlight == nil || cap(m.inFlight) != conf.MaxInFlight {
+		m.inFlight = make(chan struct{}, conf.MaxInFlight)
+	}
+	m.conf = conf
+}
+
+// rule returns the most specific rule of the call, with the percentage rolled
+func (m *Mirror) rule(serviceName, fullMethod string) (Rule, chan struct{}, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var rules []Rule
+	var patterns []string
+	for _, r := range m.conf.Rules {
+		if r.Target == "" || r.Target == "*" || r.Target == serviceName {
+			rules = append(rules, r)
+			patterns = append(patterns, r.Method)
+		}
+	}
+	i := match.Best(patterns, fullMethod)
+	if i < 0 || rules[i].Disabled || rules[i].Shadow == "" || rules[i].Shadow == serviceName {
+		return Rule{}, nil, false
+	}
+	r := rules[i]
+	return r, m.inFlight, r.Percentage == nil || rand.Float64()*100 < *r.Percentage
+}
+
+// shadowKey marks the context of the shadow calls, so that they are never mirrored again
+type shadowKey struct{}
+
+// detached keeps the values of the primary context, e.g. the metadata & the request id,
+// but not its deadline or cancellation
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+// UnaryClientInterceptor returns the mirroring interceptor for the connection of the service,
+// it matches rc.InterceptorBuilder, so it can be registered by rc.Use(mirror.UnaryClientInterceptor).
+func (m *Mirror) UnaryClientInterceptor(serviceName string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if ctx.Value(shadowKey{}) != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		rule, inFlight, ok := m.rule(serviceName, method)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		request, ok := req.(proto.Message)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		// the caller may reuse the request after the call
+		request = proto.Clone(request)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		var primary proto.Message
+		if pb, ok := reply.(proto.Message); ok && rule.Compare && err == nil {
+			primary = proto.Clone(pb)
+		}
+
+		_, name := match.Split(method)
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			droppedCounter.WithLabelValues(rule.Shadow, name).Inc()
+			return err
+		}
+		go func() {
+			defer func() { <-inFlight }()
+			m.shadow(detached{ctx}, rule, method, request, reply, primary, err)
+		}()
+		return err
+	}
+}
+
+// shadow calls the shadow service, and compares the response if primary is not nil
+func (m *Mirror) shadow(ctx context.Context, rule Rule, method string, request proto.Message, reply interface{},
+	primary proto.Message, primaryErr error) {
+	_, name := match.Split(method)
+	defer func() {
+		if r := recover(); r != nil {
+			log.CtxErrorf(ctx, "mirror: shadow call %s of %s panic: %v", method, rule.Shadow, r)
+		}
+	}()
+
+	conn := m.opts.Conn(rule.Shadow)
+	if conn == nil {
+		droppedCounter.WithLabelValues(rule.Shadow, name).Inc()
+		return
+	}
+
+	timeout := time.Duration(rule.Timeout)
+	if timeout <= 0 {
+		timeout = time.Duration(DefaultTimeout)
+	}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, shadowKey{}, true), timeout)
+	defer cancel()
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(ShadowHeader, "true")
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	response := reflect.New(reflect.TypeOf(reply).Elem()).Interface()
+	err := conn.Invoke(ctx, method, request, response)
+	mirroredCounter.WithLabelValues(rule.Shadow, name, grpc.Code(err).String()).Inc()
+
+	if !rule.Compare {
+		return
+	}
+	diffs := compare(primary, primaryErr, response, err, rule.Ignore)
+	if len(diffs) > 0 {
+		mismatchCounter.WithLabelValues(rule.Shadow, name).Inc()
+		log.CtxWarnf(ctx, "mirror: shadow %s of %s mismatches: %v", method, rule.Shadow, diffs)
+	}
+}
+
+// compare the primary & shadow results, returns the differences
+func compare(primary proto.Message, primaryErr error, shadow interface{}, shadowErr error, ignore []string) []string {
+	if x, y := grpc.Code(primaryErr), grpc.Code(shadowErr); x != y {
+		return []string{"code: " + x.String() + " != " + y.String()}
+	}
+	pb, ok := shadow.(proto.Message)
+	if primaryErr != nil || primary == nil || !ok {
+		return nil
+	}
+
+	var x, y bytes.Buffer
+	if err := js.Marshal(&x, primary); err != nil {
+		return []string{"marshal primary response error: " + err.Error()}
+	}
+	if err := js.Marshal(&y, pb); err != nil {
+		return []string{"marshal shadow response error: " + err.Error()}
+	}
+	diffs, err := record.Diff(x.Bytes(), y.Bytes(), ignore)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return diffs
+}
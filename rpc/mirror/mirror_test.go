@@ -0,0 +1,84 @@
+package mirror
+
+import (
+	"testing"
+	"time"
+
+	"gomicro/rpc/rpctest"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	// the shadow reports NOT_SERVING, and sends the shadow header to shadowed
+	shadowed := make(chan string, 10)
+	shadowServer := grpc.NewServer(grpc.UnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			shadowed <- md[ShadowHeader][0]
+			return handler(ctx, req)
+		}))
+	shadowHealth := health.NewServer()
+	shadowHealth.SetServingStatus("a", pb.HealthCheckResponse_NOT_SERVING)
+	pb.RegisterHealthServer(shadowServer, shadowHealth)
+	shadowConn := rpctest.Start(t, shadowServer)
+
+	m := New(Config{Rules: []Rule{
+		{Method: "/grpc.health.v1.Health/*", Target: "primary", Shadow: "shadow", Compare: true},
+		{Method: "/grpc.health.v1.Health/Watch", Target: "primary", Disabled: true},
+	}}, Options{Conn: func(serviceName string) *grpc.ClientConn {
+		if serviceName == "shadow" {
+			return shadowConn
+		}
+		return nil
+	}})
+
+	primaryServer := grpc.NewServer()
+	primaryHealth := health.NewServer()
+	primaryHealth.SetServingStatus("a", pb.HealthCheckResponse_SERVING)
+	pb.RegisterHealthServer(primaryServer, primaryHealth)
+	conn := rpctest.Start(t, primaryServer, grpc.WithUnaryInterceptor(m.UnaryClientInterceptor("primary")))
+
+	metrics := rpctest.SnapshotMetrics(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	resp, err := pb.NewHealthClient(conn).Check(ctx, &pb.HealthCheckRequest{Service: "a"})
+	// the shadow call is not canceled with the primary one
+	cancel()
+	if err != nil || resp.Status != pb.HealthCheckResponse_SERVING {
+		t.Fatalf("primary call error, get=%v %v", resp, err)
+	}
+
+	select {
+	case header := <-shadowed:
+		if header != "true" {
+			t.Errorf("shadow header error, get=%s", header)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("shadow call error, not called")
+	}
+
+	for i := 0; i < 100 && metrics.Delta(t, "grpc_client_mirror_mismatches_total", "grpc_target", "shadow") == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	metrics.AssertDelta(t, 1, "grpc_client_mirror_mismatches_total", "grpc_target", "shadow")
+	metrics.AssertDelta(t, 1, "grpc_client_mirrored_total", "grpc_target", "shadow", "grpc_code", "OK")
+}
+
+func TestRule(t *testing.T) {
+	never := 0.0
+	m := New(Config{Rules: []Rule{
+		{Method: "/pb.UserService/*", Shadow: "user_service_canary"},
+		{Method: "/pb.UserService/ListUsers", Shadow: "user_service_canary", Percentage: &never},
+	}}, Options{})
+
+	if _, _, ok := m.rule("user_service", "/pb.UserService/GetUser"); !ok {
+		t.Errorf("unset percentage error, get=%v", ok)
+	}
+	if _, _, ok := m.rule("user_service", "/pb.UserService/ListUsers"); ok {
+		t.Errorf("zero percentage error, get=%v", ok)
+	}
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// bucket is a token bucket, filled with rate tokens per second up to burst
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time // last time of refilling
+}
+
+func newBucket(rate float64, burst int, now time.Time) *bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// refill adds the tokens since the last refilling
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// take takes one token, returns the time to wait for the next token if the bucket is empty
+func (b *bucket) take(now time.Time) (bool, time.Duration) {
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// resize changes the rate & the burst, the tokens taken are kept
+func (b *bucket) resize(rate float64, burst int, now time.Time) {
+	b.refill(now)
+	if burst < 1 {
+		burst = 1
+	}
+	b.rate, b.burst = rate, float64(burst)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// full reports whether the bucket is refilled, so it can be dropped without changing behavior
+func (b *bucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	b := newBucket(2, 2, now)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.take(now); !ok {
+			t.Fatalf("burst token %d should be taken", i)
+		}
+	}
+
+	ok, wait := b.take(now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("empty bucket should wait 500ms, get=%v %v", ok, wait)
+	}
+
+	if ok, _ := b.take(now.Add(500 * time.Millisecond)); !ok {
+		t.Errorf("token should be refilled after 500ms")
+	}
+	if b.full(now.Add(500 * time.Millisecond)) {
+		t.Errorf("bucket should not be full")
+	}
+	if !b.full(now.Add(2 * time.Second)) {
+		t.Errorf("bucket should be full after 2s")
+	}
+}
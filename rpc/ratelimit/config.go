@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"fmt"
+
+	"gomicro/rpc/internal/config"
+)
+
+// Rule limits the methods matching Method
+type Rule struct {
+	// Method is "/pb.HelloService/NormalHello", "/pb.HelloService/*" or "*"
+	Method string `json:"method"`
+	// Key is the caller of the bucket:
+	//	""                 - no caller, the bucket is per method
+	//	"peer"             - peer ip address
+	//	"principal"        - subject of auth.Principal
+	//	"metadata:<key>"   - value of the metadata key, e.g. "metadata:x-tenant"
+	Key string `json:"key"`
+	// Shared shares one bucket among all methods matching the rule, otherwise one bucket per method
+	Shared bool `json:"shared"`
+	// Rate is the allowed requests per second, should be positive
+	Rate float64 `json:"rate"`
+	// Burst is the bucket size
+	Burst int `json:"burst"`
+}
+
+// Config of the rate limiter, the most specific rule of a method is applied
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	if err := config.Load("ratelimit", path, &conf); err != nil {
+		return conf, err
+	}
+	return conf, conf.validate()
+}
+
+func (c *Config) validate() error {
+	for _, r := range c.Rules {
+		if r.Method == "" {
+			return fmt.Errorf("ratelimit: rule without method")
+		}
+		if r.Rate <= 0 {
+			return fmt.Errorf("ratelimit: rate of '%s' should be positive", r.Method)
+		}
+		switch {
+		case r.Key == "", r.Key == "peer", r.Key == "principal":
+		case len(r.Key) > len("metadata:") && r.Key[:len("metadata:")] == "metadata:":
+		default:
+			return fmt.Errorf("ratelimit: unknown key '%s' of '%s'", r.Key, r.Method)
+		}
+	}
+	return nil
+}
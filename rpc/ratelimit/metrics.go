@@ -0,0 +1,18 @@
+package ratelimit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// named like grpc_prometheus metrics, labeled by grpc_service & grpc_method
+var rejectedCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "grpc",
+		Subsystem: "server",
+		Name:      "ratelimited_total",
+		Help:      "Total number of RPCs rejected by the rate limiter on the server.",
+	}, []string{"grpc_service", "grpc_method"})
+
+func init() {
+	prometheus.MustRegister(rejectedCounter)
+}
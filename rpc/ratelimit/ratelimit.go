@@ -0,0 +1,205 @@
+// Package ratelimit is the server side rate limiting interceptor for grpc.
+// Requests are counted by token buckets keyed by method, by caller or both,
+// the rules come from Config and can be replaced at runtime by Limiter.Update.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rpc/auth"
+	"gomicro/rpc/internal/match"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// RetryAfterKey is the trailer telling the client how many seconds to wait before retrying
+const RetryAfterKey = "retry-after"
+
+// idle buckets are dropped every sweepInterval
+const sweepInterval = time.Minute
+
+// Limiter holds the rules & the token buckets
+type Limiter struct {
+	lock      sync.Mutex
+	conf      Config
+	patterns  []string
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New return a Limiter with the config
+func New(conf Config) (*Limiter, error) {
+	l := &Limiter{}
+	if err := l.Update(conf); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update replaces the rules, the buckets of the rules kept with the same method,
+// key & sharing are resized to the new rate & burst, the others are dropped
+func (l *Limiter) Update(conf Config) error {
+	if err := conf.validate(); err != nil {
+		return err
+	}
+
+	patterns := make([]string, len(conf.Rules))
+	for i, r := range conf.Rules {
+		patterns[i] = r.Method
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	kept := make(map[string]*Rule)
+	for i := range conf.Rules {
+		r := &conf.Rules[i]
+		for _, old := range l.conf.Rules {
+			if old.Method == r.Method && old.Key == r.Key && old.Shared == r.Shared {
+				kept[r.Method] = r
+			}
+		}
+	}
+	for key, b := range l.buckets {
+		if r, ok := kept[key[:strings.Index(key, "|")]]; ok {
+			b.resize(r.Rate, r.Burst, now)
+		} else {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.conf = conf
+	l.patterns = patterns
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucket)
+		l.lastSweep = now
+	}
+	return nil
+}
+
+// Config returns the current config
+func (l *Limiter) Config() Config {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.conf
+}
+
+// Allow takes a token for the call, returns the time to wait if it is rejected
+func (l *Limiter) Allow(ctx context.Context, fullMethod string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	i := match.Best(l.patterns, fullMethod)
+	if i < 0 {
+		return true, 0
+	}
+	rule := &l.conf.Rules[i]
+
+	// keyed by the rule method to be kept by Update
+	key := rule.Method + "|"
+	if !rule.Shared {
+		key += fullMethod
+	}
+	if rule.Key != "" {
+		key += "|" + callerKey(ctx, rule.Key)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newBucket(rule.Rate, rule.Burst, now)
+		l.buckets[key] = b
+	}
+	allowed, wait := b.take(now)
+
+	l.sweep(now)
+	return allowed, wait
+}
+
+// sweep drops the refilled buckets, otherwise the callers would grow the map forever
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// callerKey returns the identity of the caller, "" if unknown
+func callerKey(ctx context.Context, kind string) string {
+	switch {
+	case kind == "peer":
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+				return host
+			}
+			return p.Addr.String()
+		}
+	case kind == "principal":
+		if p := auth.FromContext(ctx); p != nil {
+			return p.Subject
+		}
+	case strings.HasPrefix(kind, "metadata:"):
+		if meta, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := meta[kind[len("metadata:"):]]; len(values) > 0 {
+				return values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// UnaryServerInterceptor rejects the unary calls over the limit with ResourceExhausted
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if allowed, wait := l.Allow(ctx, info.FullMethod); !allowed {
+			grpc.SetTrailer(ctx, retryAfter(wait))
+			return nil, rejected(ctx, info.FullMethod, wait)
+		}
+		return handler(ctx, request)
+	}
+}
+
+// StreamServerInterceptor rejects the stream calls over the limit with ResourceExhausted
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if allowed, wait := l.Allow(stream.Context(), info.FullMethod); !allowed {
+			stream.SetTrailer(retryAfter(wait))
+			return rejected(stream.Context(), info.FullMethod, wait)
+		}
+		return handler(srv, stream)
+	}
+}
+
+func retryAfter(wait time.Duration) metadata.MD {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return metadata.Pairs(RetryAfterKey, strconv.Itoa(seconds))
+}
+
+func rejected(ctx context.Context, fullMethod string, wait time.Duration) error {
+	service, method := match.Split(fullMethod)
+	rejectedCounter.WithLabelValues(service, method).Inc()
+
+	log.CtxDebugf(ctx, "rate limited %s, retry after %v", fullMethod, wait)
+	return grpc.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %v", wait)
+}
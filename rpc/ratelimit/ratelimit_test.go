@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestUpdate(t *testing.T) {
+	method := "/pb.HelloService/NormalHello"
+	l, err := New(Config{Rules: []Rule{{Method: "*", Rate: 0.001, Burst: 1}}})
+	if err != nil {
+		t.Fatalf("new error: %v", err)
+	}
+	if ok, _ := l.Allow(context.Background(), method); !ok {
+		t.Fatalf("first call should be allowed")
+	}
+
+	// the bucket is kept, a reload does not refill it
+	if err := l.Update(Config{Rules: []Rule{{Method: "*", Rate: 0.001, Burst: 5}}}); err != nil {
+		t.Fatalf("update error: %v", err)
+	}
+	if ok, _ := l.Allow(context.Background(), method); ok {
+		t.Errorf("bucket should be kept by update")
+	}
+
+	// the bucket of a changed rule is dropped
+	if err := l.Update(Config{Rules: []Rule{{Method: "*", Shared: true, Rate: 0.001, Burst: 1}}}); err != nil {
+		t.Fatalf("update error: %v", err)
+	}
+	if ok, _ := l.Allow(context.Background(), method); !ok {
+		t.Errorf("bucket of the changed rule should be dropped")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, r := range []Rule{
+		{Rate: 1},
+		{Method: "*"},
+		{Method: "*", Rate: -1},
+		{Method: "*", Rate: 1, Key: "cookie"},
+	} {
+		if _, err := New(Config{Rules: []Rule{r}}); err == nil {
+			t.Errorf("validate error, get=nil, rule=%+v", r)
+		}
+	}
+}
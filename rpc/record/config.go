@@ -0,0 +1,43 @@
+package record
+
+import (
+	"gomicro/rpc/internal/config"
+)
+
+// Format of the recorded payloads
+type Format string
+
+const (
+	// FormatJSON records the payloads as jsonpb, readable but loses the unknown fields
+	FormatJSON Format = "json"
+	// FormatBinary records the payloads as the proto wire format
+	FormatBinary Format = "binary"
+)
+
+// Rule is the recording of the methods matching Method
+type Rule struct {
+	// Method is "/pb.HelloService/NormalHello", "/pb.HelloService/*" or "*"
+	Method string `json:"method"`
+	// Percentage of the calls to record, 0-100, 100 if unset
+	Percentage *float64 `json:"percentage,omitempty"`
+	// Disabled turns off the recording of the methods
+	Disabled bool `json:"disabled"`
+	// Metadata keys to record, e.g. ["x-user-id"], none if empty,
+	// do not record the credentials like "authorization"
+	Metadata []string `json:"metadata"`
+}
+
+// Config of the recording, the most specific rule of a method is applied,
+// methods matching no rule are not recorded
+type Config struct {
+	Rules []Rule `json:"rules"`
+	// Format of the payloads, FormatJSON if empty
+	Format Format `json:"format"`
+}
+
+// LoadConfig reads the json config file
+func LoadConfig(path string) (Config, error) {
+	var conf Config
+	err := config.Load("record", path, &conf)
+	return conf, err
+}
@@ -0,0 +1,98 @@
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Diff compares the json payloads, returns the differences like "items[1].price: 10 != 12".
+// The ignored fields are the dot paths of the field names, e.g. "header.request_id",
+// the list indexes are not part of the paths, so "items.price" ignores the price of all items.
+func Diff(expected, actual []byte, ignore []string) ([]string, error) {
+	var x, y interface{}
+	if len(expected) > 0 {
+		if err := json.Unmarshal(expected, &x); err != nil {
+			return nil, fmt.Errorf("record: decode expected error: %v", err)
+		}
+	}
+	if len(actual) > 0 {
+		if err := json.Unmarshal(actual, &y); err != nil {
+			return nil, fmt.Errorf("record: decode actual error: %v", err)
+		}
+	}
+
+	ignored := make(map[string]bool, len(ignore))
+	for _, path := range ignore {
+		ignored[path] = true
+	}
+
+	var diffs []string
+	diff(x, y, "", "", ignored, &diffs)
+	return diffs, nil
+}
+
+// diff appends the differences of x & y to diffs, path is shown & field is matched against the ignored
+func diff(x, y interface{}, path, field string, ignored map[string]bool, diffs *[]string) {
+	if field != "" && ignored[field] {
+		return
+	}
+
+	switch x := x.(type) {
+	case map[string]interface{}:
+		if y, ok := y.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(x)+len(y))
+			for k := range x {
+				keys = append(keys, k)
+			}
+			for k := range y {
+				if _, ok := x[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				diff(x[k], y[k], join(path, k), join(field, k), ignored, diffs)
+			}
+			return
+		}
+	case []interface{}:
+		if y, ok := y.([]interface{}); ok {
+			for i := 0; i < len(x) || i < len(y); i++ {
+				var xi, yi interface{}
+				if i < len(x) {
+					xi = x[i]
+				}
+				if i < len(y) {
+					yi = y[i]
+				}
+				diff(xi, yi, fmt.Sprintf("%s[%d]", path, i), field, ignored, diffs)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(x, y) {
+		if path == "" {
+			path = "."
+		}
+		*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s", path, show(x), show(y)))
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func show(v interface{}) string {
+	if v == nil {
+		return "<missing>"
+	}
+	data, _ := json.Marshal(v)
+	return strings.TrimSpace(string(data))
+}
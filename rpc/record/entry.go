@@ -0,0 +1,114 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// maxEntryBytes bounds an entry line read by ReadEntries
+const maxEntryBytes = 16 << 20
+
+var js = &jsonpb.Marshaler{OrigName: true}
+
+// Entry is a recorded call, written as a json line
+type Entry struct {
+	Time     time.Time           `json:"time"`
+	Method   string              `json:"method"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+	Format   Format              `json:"format"`
+	// Request & Response are the jsonpb payloads of FormatJSON
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// RequestBytes & ResponseBytes are the binary payloads of FormatBinary
+	RequestBytes  []byte `json:"request_bytes,omitempty"`
+	ResponseBytes []byte `json:"response_bytes,omitempty"`
+	Code          string `json:"code"`
+	Message       string `json:"message,omitempty"`
+	// Duration of the call in seconds
+	Duration float64 `json:"duration"`
+}
+
+// setPayloads encodes the request & response in e.Format, the response may be nil
+func (e *Entry) setPayloads(request, response proto.Message) error {
+	if e.Format == "" {
+		e.Format = FormatJSON
+	}
+
+	if e.Format == FormatBinary {
+		data, err := proto.Marshal(request)
+		if err != nil {
+			return err
+		}
+		e.RequestBytes = data
+		if response != nil {
+			if e.ResponseBytes, err = proto.Marshal(response); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := js.Marshal(&buf, request); err != nil {
+		return err
+	}
+	e.Request = buf.Bytes()
+	if response != nil {
+		var buf bytes.Buffer
+		if err := js.Marshal(&buf, response); err != nil {
+			return err
+		}
+		e.Response = buf.Bytes()
+	}
+	return nil
+}
+
+// DecodeRequest decodes the recorded request into m, m may be a dynamic message
+func (e *Entry) DecodeRequest(m proto.Message) error {
+	return e.decode(e.Request, e.RequestBytes, m)
+}
+
+// DecodeResponse decodes the recorded response into m, m may be a dynamic message
+func (e *Entry) DecodeResponse(m proto.Message) error {
+	return e.decode(e.Response, e.ResponseBytes, m)
+}
+
+func (e *Entry) decode(js json.RawMessage, data []byte, m proto.Message) error {
+	if e.Format == FormatBinary {
+		return proto.Unmarshal(data, m)
+	}
+	if len(js) == 0 {
+		return nil
+	}
+	return (&jsonpb.Unmarshaler{AllowUnknownFields: true}).Unmarshal(bytes.NewReader(js), m)
+}
+
+// ReadEntries reads the entries from r, calls fn for each of them till fn returns an error
+func ReadEntries(r io.Reader, fn func(e *Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEntryBytes)
+
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		e := &Entry{}
+		if err := json.Unmarshal(line, e); err != nil {
+			return fmt.Errorf("record: entry %d: %v", n, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
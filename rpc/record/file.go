@@ -0,0 +1,96 @@
+package record
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DefaultMaxBytes & DefaultMaxBackups of the RotatingFile
+const (
+	DefaultMaxBytes   = 100 << 20
+	DefaultMaxBackups = 5
+)
+
+// RotatingFile is a file renamed to path.1, path.2, ... when it exceeds the max bytes,
+// the oldest beyond the max backups is removed
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens the file to append, DefaultMaxBytes & DefaultMaxBackups are used if 0
+func NewRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+
+	f := &RotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("record: open %s error: %v", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("record: stat %s error: %v", f.path, err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for n := f.maxBackups - 1; n > 0; n-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, n), fmt.Sprintf("%s.%d", f.path, n+1))
+	}
+	err := os.Rename(f.path, f.path+".1")
+	// reopen anyway, so that the recording goes on in the current file if the rename fails
+	if e := f.open(); e != nil {
+		return e
+	}
+	if err != nil {
+		return fmt.Errorf("record: rotate %s error: %v", f.path, err)
+	}
+	return nil
+}
+
+// Write implements io.Writer, p is never split across the files
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close implements io.Closer
+func (f *RotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Close()
+}
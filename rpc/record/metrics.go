@@ -0,0 +1,27 @@
+package record
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	recordedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "recorded_total",
+			Help:      "Total number of RPCs recorded on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+
+	droppedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "record_dropped_total",
+			Help:      "Total number of sampled RPCs dropped as the recording falls behind on the server.",
+		}, []string{"grpc_service", "grpc_method"})
+)
+
+func init() {
+	prometheus.MustRegister(recordedCounter, droppedCounter)
+}
@@ -0,0 +1,180 @@
+// Package record samples the request & response pairs of the grpc calls into a file,
+// to be replayed against a new build by cmd/gomicro-replay for regression tests.
+// The entries are written asynchronously, they are dropped if the writer falls behind,
+// so the recording never slows the calls down.
+package record
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rpc/internal/match"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// queueSize is the entries waiting to be written
+const queueSize = 1024
+
+// Recorder records the sampled calls
+type Recorder struct {
+	w     io.Writer
+	queue chan *pending
+	done  chan struct{}
+
+	lock     sync.RWMutex
+	conf     Config
+	patterns []string
+	closed   bool // the queue is closed
+}
+
+// pending is an entry with the payloads to be encoded, off the call path
+type pending struct {
+	entry    *Entry
+	request  proto.Message
+	response proto.Message
+}
+
+// New returns a Recorder writing to w, e.g. a RotatingFile, it must be closed to flush the entries
+func New(w io.Writer, conf Config) *Recorder {
+	r := &Recorder{w: w, queue: make(chan *pending, queueSize), done: make(chan struct{})}
+	r.Update(conf)
+	go r.loop()
+	return r
+}
+
+// Update replaces the rules at runtime
+func (r *Recorder) Update(conf Config) {
+	if conf.Format == "" {
+		conf.Format = FormatJSON
+	}
+	patterns := make([]string, len(conf.Rules))
+	for i, rule := range conf.Rules {
+		patterns[i] = rule.Method
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.conf = conf
+	r.patterns = patterns
+}
+
+// rule returns the rule of the method, with the percentage rolled
+func (r *Recorder) rule(fullMethod string) (Rule, Format, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	i := match.Best(r.patterns, fullMethod)
+	if i < 0 || r.conf.Rules[i].Disabled {
+		return Rule{}, "", false
+	}
+	rule := r.conf.Rules[i]
+	return rule, r.conf.Format, rule.Percentage == nil || rand.Float64()*100 < *rule.Percentage
+}
+
+func (r *Recorder) loop() {
+	defer close(r.done)
+	for p := range r.queue {
+		e := p.entry
+		err := e.setPayloads(p.request, p.response)
+		if err == nil {
+			var data []byte
+			if data, err = json.Marshal(e); err == nil {
+				_, err = r.w.Write(append(data, '\n'))
+			}
+		}
+		if err != nil {
+			log.Errorf("record: write entry of %s error: %v", e.Method, err)
+		}
+	}
+}
+
+// Close flushes the entries and closes the writer if it is an io.Closer,
+// the calls after Close are not recorded
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.queue)
+	r.lock.Unlock()
+
+	<-r.done
+	if c, ok := r.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// enqueue the entry to be written, it is dropped if the queue is full or closed
+func (r *Recorder) enqueue(fullMethod string, p *pending) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.closed {
+		return
+	}
+
+	service, method := match.Split(fullMethod)
+	select {
+	case r.queue <- p:
+		recordedCounter.WithLabelValues(service, method).Inc()
+	default:
+		droppedCounter.WithLabelValues(service, method).Inc()
+	}
+}
+
+// UnaryServerInterceptor records the sampled unary calls, stream calls are not recorded
+func (r *Recorder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule, format, ok := r.rule(info.FullMethod)
+		if !ok {
+			return handler(ctx, request)
+		}
+
+		start := time.Now()
+		response, err := handler(ctx, request)
+		cost := time.Since(start)
+
+		req, ok := request.(proto.Message)
+		if !ok {
+			return response, err
+		}
+		e := &Entry{
+			Time:     start.UTC(),
+			Method:   info.FullMethod,
+			Format:   format,
+			Code:     grpc.Code(err).String(),
+			Duration: cost.Seconds(),
+		}
+		if err != nil {
+			e.Message = grpc.ErrorDesc(err)
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			for _, key := range rule.Metadata {
+				if values := md[key]; len(values) > 0 {
+					if e.Metadata == nil {
+						e.Metadata = make(map[string][]string)
+					}
+					e.Metadata[key] = values
+				}
+			}
+		}
+		resp, _ := response.(proto.Message)
+		if err != nil || resp != nil && reflect.ValueOf(resp).IsNil() {
+			resp = nil
+		}
+
+		r.enqueue(info.FullMethod, &pending{entry: e, request: req, response: resp})
+		return response, err
+	}
+}
@@ -0,0 +1,105 @@
+package record
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRecorder(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatBinary} {
+		path := filepath.Join(t.TempDir(), "record.log")
+		file, err := NewRotatingFile(path, 0, 0)
+		if err != nil {
+			t.Fatalf("open file error, get=%v", err)
+		}
+		never := 0.0
+		r := New(file, Config{Format: format, Rules: []Rule{
+			{Method: "/grpc.health.v1.Health/*", Metadata: []string{"x-user-id"}},
+			{Method: "/grpc.health.v1.Health/Watch", Disabled: true},
+			{Method: "/grpc.health.v1.Health/List", Percentage: &never},
+		}})
+		interceptor := r.UnaryServerInterceptor()
+
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			if req.(*pb.HealthCheckRequest).Service == "unknown" {
+				return nil, grpc.Errorf(codes.NotFound, "unknown service")
+			}
+			return &pb.HealthCheckResponse{Status: pb.HealthCheckResponse_SERVING}, nil
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "42", "authorization", "secret"))
+		interceptor(ctx, &pb.HealthCheckRequest{Service: "hello"}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+		interceptor(ctx, &pb.HealthCheckRequest{Service: "unknown"}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+		interceptor(ctx, &pb.HealthCheckRequest{}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, handler)
+		interceptor(ctx, &pb.HealthCheckRequest{}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/List"}, handler)
+		r.Close()
+		// the calls after Close are not recorded
+		interceptor(ctx, &pb.HealthCheckRequest{Service: "hello"}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+		if err := r.Close(); err != nil {
+			t.Errorf("%s close twice error, get=%v", format, err)
+		}
+
+		f, _ := os.Open(path)
+		var entries []*Entry
+		ReadEntries(f, func(e *Entry) error {
+			entries = append(entries, e)
+			return nil
+		})
+		f.Close()
+		if len(entries) != 2 {
+			t.Fatalf("%s entries error, get=%d", format, len(entries))
+		}
+
+		req, resp := &pb.HealthCheckRequest{}, &pb.HealthCheckResponse{}
+		e := entries[0]
+		if err := e.DecodeRequest(req); err != nil || req.Service != "hello" {
+			t.Errorf("%s request error, get=%v %v", format, req, err)
+		}
+		if err := e.DecodeResponse(resp); err != nil || resp.Status != pb.HealthCheckResponse_SERVING {
+			t.Errorf("%s response error, get=%v %v", format, resp, err)
+		}
+		if !reflect.DeepEqual(e.Metadata, map[string][]string{"x-user-id": {"42"}}) || e.Format != format {
+			t.Errorf("%s metadata error, get=%v %s", format, e.Metadata, e.Format)
+		}
+		if e := entries[1]; e.Code != "NotFound" || e.Message != "unknown service" {
+			t.Errorf("%s status error, get=%s %s", format, e.Code, e.Message)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.log")
+	f, _ := NewRotatingFile(path, 10, 2)
+	for _, s := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		f.Write([]byte(s))
+	}
+	f.Close()
+
+	for name, want := range map[string]string{path: "dddddddd\n", path + ".1": "cccccccc\n", path + ".2": "bbbbbbbb\n"} {
+		if data, _ := ioutil.ReadFile(name); string(data) != want {
+			t.Errorf("rotate %s error, get=%q", name, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("max backups error, get=%v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	expected := []byte(`{"id": "1", "items": [{"price": 10, "ts": 1}, {"price": 20, "ts": 2}], "tags": ["a"]}`)
+	actual := []byte(`{"id": "2", "items": [{"price": 10, "ts": 3}, {"price": 21, "ts": 4}], "extra": true}`)
+
+	diffs, err := Diff(expected, actual, []string{"id", "items.ts"})
+	want := []string{"extra: <missing> != true", "items[1].price: 20 != 21", `tags: ["a"] != <missing>`}
+	if err != nil || !reflect.DeepEqual(diffs, want) {
+		t.Errorf("diff error, get=%q %v", diffs, err)
+	}
+}
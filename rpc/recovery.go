@@ -1,33 +1,116 @@
 package rpc
 
 import (
-	"runtime"
+	"fmt"
+	"runtime/debug"
+	"sync"
 
 	"gomicro/log"
+	"gomicro/rpc/internal/match"
 
+	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
 	// MaxStackSize runtime输出缓冲
+	//
+	// Deprecated: the full stack is logged now.
 	MaxStackSize = 4096
+
+	// IncidentKey is the trailer key of the incident ID of a panic
+	IncidentKey = "x-incident-id"
+)
+
+// PanicHandler is called after a panic is recovered, e.g. to alert
+type PanicHandler func(ctx context.Context, fullMethod, incident string, r interface{}, stack []byte)
+
+// RecoveryConfig of the Recovery interceptors
+type RecoveryConfig struct {
+	// Handler is called with the panic, optional
+	Handler PanicHandler
+	// RePanic panics again after logging, for tests to fail loudly
+	RePanic bool
+}
+
+var (
+	recoveryConfig RecoveryConfig
+	recoveryLock   sync.RWMutex
+
+	panicsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "grpc",
+			Subsystem: "server",
+			Name:      "panics_total",
+			Help:      "Total number of panics recovered on the server.",
+		}, []string{"grpc_service", "grpc_method"})
 )
 
+func init() {
+	prometheus.MustRegister(panicsCounter)
+}
+
+// SetRecoveryConfig changes the config of Recovery & StreamRecovery
+func SetRecoveryConfig(conf RecoveryConfig) {
+	recoveryLock.Lock()
+	recoveryConfig = conf
+	recoveryLock.Unlock()
+}
+
+// recovered logs the panic with the full stack, and returns the incident ID
+func recovered(ctx context.Context, fullMethod string, r interface{}) string {
+	stack := debug.Stack()
+	incident := uuid.New()
+	log.CtxErrorf(ctx, "panic grpc invoke: %s, incident=%s, err=%v, stack:\n%s", fullMethod, incident, r, stack)
+
+	service, method := match.Split(fullMethod)
+	panicsCounter.WithLabelValues(service, method).Inc()
+
+	recoveryLock.RLock()
+	conf := recoveryConfig
+	recoveryLock.RUnlock()
+
+	if conf.Handler != nil {
+		conf.Handler(ctx, fullMethod, incident, r, stack)
+	}
+	if conf.RePanic {
+		panic(fmt.Sprintf("re-panic of incident %s: %v", incident, r))
+	}
+	return incident
+}
+
+// incidentError hides the panic from the client, telling the incident ID only
+func incidentError(incident string) error {
+	return grpc.Errorf(codes.Internal, "internal error, incident=%s", incident)
+}
+
 // Recovery interceptor to handle grpc panic
 func Recovery(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			// log stack
-			stack := make([]byte, MaxStackSize)
-			stack = stack[:runtime.Stack(stack, false)]
-			log.CtxErrorf(ctx, "panic grpc invoke: %s, err=%v, stack:\n%s", info.FullMethod, r, string(stack))
-
 			// if panic, set custom error to 'err', in order that client and sense it.
-			err = grpc.Errorf(codes.Internal, "panic error: %v", r)
+			incident := recovered(ctx, info.FullMethod, r)
+			grpc.SetTrailer(ctx, metadata.Pairs(IncidentKey, incident))
+			err = incidentError(incident)
 		}
 	}()
 
 	return handler(ctx, request)
 }
+
+// StreamRecovery interceptor to handle grpc stream panic
+func StreamRecovery(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			incident := recovered(stream.Context(), info.FullMethod, r)
+			stream.SetTrailer(metadata.Pairs(IncidentKey, incident))
+			err = incidentError(incident)
+		}
+	}()
+
+	return handler(srv, stream)
+}
@@ -0,0 +1,39 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRecovery(t *testing.T) {
+	var incident string
+	SetRecoveryConfig(RecoveryConfig{Handler: func(ctx context.Context, fullMethod, id string, r interface{}, stack []byte) {
+		incident = id
+	}})
+	defer SetRecoveryConfig(RecoveryConfig{})
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.HelloService/PanicHello"}
+	_, err := Recovery(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("password=secret")
+	})
+	if grpc.Code(err) != codes.Internal || strings.Contains(err.Error(), "secret") {
+		t.Errorf("recovery error, get=%v", err)
+	}
+	if incident == "" || !strings.Contains(err.Error(), incident) {
+		t.Errorf("incident error, get=%v, want=%s", err, incident)
+	}
+
+	SetRecoveryConfig(RecoveryConfig{RePanic: true})
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("re-panic error, get=nil")
+		}
+	}()
+	Recovery(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("again")
+	})
+}
@@ -0,0 +1,57 @@
+package retry
+
+import (
+	"sync"
+)
+
+// Budget throttles the retries when too many calls fail, to avoid retry storms.
+// Every retryable failure takes one token, every success gives back TokenRatio tokens,
+// retrying is allowed only when more than half of MaxTokens remain.
+type Budget struct {
+	MaxTokens  float64
+	TokenRatio float64
+
+	lock   sync.Mutex
+	tokens float64
+}
+
+// NewBudget return a full Budget
+func NewBudget(maxTokens, tokenRatio float64) *Budget {
+	return &Budget{MaxTokens: maxTokens, TokenRatio: tokenRatio, tokens: maxTokens}
+}
+
+// Allow reports whether a retry may be sent
+func (b *Budget) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.tokens > b.MaxTokens/2
+}
+
+// Success records a succeeded attempt
+func (b *Budget) Success() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.tokens += b.TokenRatio
+	if b.tokens > b.MaxTokens {
+		b.tokens = b.MaxTokens
+	}
+}
+
+// Failure records an attempt failed with a retryable code
+func (b *Budget) Failure() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.tokens--
+	if b.tokens < 0 {
+		b.tokens = 0
+	}
+}
@@ -0,0 +1,88 @@
+package retry
+
+import (
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Policy is the retry policy of the methods matching Method
+type Policy struct {
+	// Method is "/pb.HelloService/NormalHello", "/pb.HelloService/*" or "*"
+	Method string
+	// MaxAttempts includes the first call, 1 disables retrying
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, 100ms if zero
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff, 5s if zero
+	MaxBackoff time.Duration
+	// Multiplier of the backoff on every retry, 2 if zero
+	Multiplier float64
+	// Jitter randomizes the backoff by +/- Jitter*backoff, 0.2 if zero, negative to disable
+	Jitter float64
+	// RetryableCodes are the codes worth retrying, only Unavailable if empty
+	RetryableCodes []codes.Code
+	// PerAttemptTimeout is the timeout of every attempt, no timeout if zero
+	PerAttemptTimeout time.Duration
+	// Timeout is the overall deadline budget of all attempts, it only shortens the caller deadline
+	Timeout time.Duration
+	// Idempotent methods may be hedged and retried on DeadlineExceeded of an attempt
+	Idempotent bool
+	// HedgingDelay sends another attempt if there is no response after it, only for Idempotent methods
+	HedgingDelay time.Duration
+}
+
+func (p *Policy) withDefaults() Policy {
+	policy := *p
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 5 * time.Second
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	if policy.Jitter == 0 {
+		policy.Jitter = 0.2
+	}
+	if len(policy.RetryableCodes) == 0 {
+		policy.RetryableCodes = []codes.Code{codes.Unavailable}
+	}
+	return policy
+}
+
+func (p *Policy) retryable(code codes.Code) bool {
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	// a timed out attempt of an idempotent method is safe to send again
+	return code == codes.DeadlineExceeded && p.Idempotent && p.PerAttemptTimeout > 0
+}
+
+func (p *Policy) hedging() bool {
+	return p.Idempotent && p.HedgingDelay > 0 && p.MaxAttempts > 1
+}
+
+// backoff returns the wait before the retry, retry starts from 1
+func (p *Policy) backoff(retry int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		backoff *= p.Multiplier
+		if backoff > float64(p.MaxBackoff) {
+			backoff = float64(p.MaxBackoff)
+			break
+		}
+	}
+
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(backoff)
+}
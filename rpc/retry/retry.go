@@ -0,0 +1,246 @@
+// Package retry is the client interceptor retrying failed grpc calls with exponential backoff.
+// Idempotent methods can be hedged: another attempt is sent if the former one does not
+// respond after a delay, the first success wins. The retries of a target service are
+// throttled by a Budget to avoid retry storms.
+package retry
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"gomicro/log"
+	"gomicro/rpc/internal/match"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Options of the Retryer
+type Options struct {
+	// Policies of the methods, the most specific one is applied
+	Policies []Policy
+	// MaxTokens & TokenRatio of the Budget of every target service, no budget if MaxTokens is zero
+	MaxTokens  float64
+	TokenRatio float64
+}
+
+// Retryer retries the calls by the policies
+type Retryer struct {
+	opts     Options
+	policies []Policy
+	patterns []string
+
+	lock    sync.Mutex
+	budgets map[string]*Budget
+}
+
+// New return a Retryer
+func New(opts Options) *Retryer {
+	r := &Retryer{opts: opts, budgets: make(map[string]*Budget)}
+	for _, p := range opts.Policies {
+		r.policies = append(r.policies, p.withDefaults())
+		r.patterns = append(r.patterns, p.Method)
+	}
+	return r
+}
+
+func (r *Retryer) budget(serviceName string) *Budget {
+	if r.opts.MaxTokens <= 0 {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	b, ok := r.budgets[serviceName]
+	if !ok {
+		b = NewBudget(r.opts.MaxTokens, r.opts.TokenRatio)
+		r.budgets[serviceName] = b
+	}
+	return b
+}
+
+// UnaryClientInterceptor returns the retry interceptor for the connection of the service,
+// it matches rc.InterceptorBuilder, so it can be registered by rc.Use(retryer.UnaryClientInterceptor).
+func (r *Retryer) UnaryClientInterceptor(serviceName string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		i := match.Best(r.patterns, method)
+		if i < 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		policy := &r.policies[i]
+
+		if policy.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+			defer cancel()
+		}
+
+		c := &call{
+			policy:  policy,
+			budget:  r.budget(serviceName),
+			method:  method,
+			req:     req,
+			cc:      cc,
+			invoker: invoker,
+			opts:    opts,
+		}
+		if policy.hedging() {
+			if msg, ok := reply.(proto.Message); ok {
+				return c.hedge(ctx, msg)
+			}
+		}
+		return c.retry(ctx, reply)
+	}
+}
+
+// call is one rpc with its attempts
+type call struct {
+	policy  *Policy
+	budget  *Budget
+	method  string
+	req     interface{}
+	cc      *grpc.ClientConn
+	invoker grpc.UnaryInvoker
+	opts    []grpc.CallOption
+}
+
+func (c *call) attempt(ctx context.Context, reply interface{}, trailer *metadata.MD) error {
+	if c.policy.PerAttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.policy.PerAttemptTimeout)
+		defer cancel()
+	}
+
+	opts := make([]grpc.CallOption, len(c.opts), len(c.opts)+1)
+	copy(opts, c.opts)
+	if trailer != nil {
+		opts = append(opts, grpc.Trailer(trailer))
+	}
+	return c.invoker(ctx, c.method, c.req, reply, c.cc, opts...)
+}
+
+// retry sends the attempts one by one
+func (c *call) retry(ctx context.Context, reply interface{}) error {
+	for attempt := 1; ; attempt++ {
+		var trailer metadata.MD
+		err := c.attempt(ctx, reply, &trailer)
+		if err == nil {
+			c.budget.Success()
+			return nil
+		}
+		// only the retryable failures are charged, a NotFound is not an overloaded server
+		if !c.policy.retryable(grpc.Code(err)) {
+			return err
+		}
+		c.budget.Failure()
+
+		if attempt >= c.policy.MaxAttempts || ctx.Err() != nil || !c.budget.Allow() {
+			return err
+		}
+
+		// respect the retry-after trailer of the server, e.g. set by the rate limiter
+		wait := c.policy.backoff(attempt)
+		if after := retryAfter(trailer); after > wait {
+			wait = after
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return err
+		}
+
+		log.CtxDebugf(ctx, "retry %s in %v, attempt=%d, err=%v", c.method, wait, attempt, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+type result struct {
+	reply proto.Message
+	err   error
+}
+
+// hedge sends another attempt every HedgingDelay or at once after a retryable failure,
+// the first success is copied to reply and the other attempts are canceled.
+func (c *call) hedge(ctx context.Context, reply proto.Message) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, c.policy.MaxAttempts)
+	replyType := reflect.TypeOf(reply).Elem()
+	send := func() {
+		out := reflect.New(replyType).Interface().(proto.Message)
+		go func() {
+			results <- result{reply: out, err: c.attempt(ctx, out, nil)}
+		}()
+	}
+
+	sent, pending := 1, 1
+	send()
+
+	timer := time.NewTimer(c.policy.HedgingDelay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				c.budget.Success()
+				reply.Reset()
+				proto.Merge(reply, res.reply)
+				return nil
+			}
+			lastErr = res.err
+
+			// a fatal code means the other attempts would fail too
+			if !c.policy.retryable(grpc.Code(res.err)) {
+				return res.err
+			}
+			c.budget.Failure()
+			if sent < c.policy.MaxAttempts && ctx.Err() == nil && c.budget.Allow() {
+				log.CtxDebugf(ctx, "hedge %s after failure, attempt=%d, err=%v", c.method, sent+1, res.err)
+				send()
+				sent, pending = sent+1, pending+1
+				resetTimer(timer, c.policy.HedgingDelay)
+			}
+
+		case <-timer.C:
+			if sent < c.policy.MaxAttempts && c.budget.Allow() {
+				log.CtxDebugf(ctx, "hedge %s after %v, attempt=%d", c.method, c.policy.HedgingDelay, sent+1)
+				send()
+				sent, pending = sent+1, pending+1
+				timer.Reset(c.policy.HedgingDelay)
+			}
+		}
+	}
+	return lastErr
+}
+
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// retryAfter parses the retry-after trailer in seconds
+func retryAfter(trailer metadata.MD) time.Duration {
+	if values := trailer["retry-after"]; len(values) > 0 {
+		if seconds, err := strconv.Atoi(values[0]); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestBackoff(t *testing.T) {
+	p := (&Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: -1}).withDefaults()
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) error, get=%v, want=%v", i+1, got, w)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(4, 1)
+	b.Failure()
+	if !b.Allow() {
+		t.Errorf("retry should be allowed with 3 tokens")
+	}
+	b.Failure()
+	if b.Allow() {
+		t.Errorf("retry should be throttled with 2 tokens")
+	}
+	b.Success()
+	if !b.Allow() {
+		t.Errorf("retry should be allowed again after success")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	r := New(Options{Policies: []Policy{{Method: "*", MaxAttempts: 3, InitialBackoff: time.Millisecond}}})
+	interceptor := r.UnaryClientInterceptor("hello_service")
+
+	attempts := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts++
+		if attempts < 3 {
+			return grpc.Errorf(codes.Unavailable, "instance removed")
+		}
+		return nil
+	}
+
+	err := interceptor(context.Background(), "/pb.HelloService/NormalHello", nil, nil, nil, invoker)
+	if err != nil || attempts != 3 {
+		t.Errorf("retry error, attempts=%d, err=%v", attempts, err)
+	}
+}
+
+func TestHedge(t *testing.T) {
+	r := New(Options{Policies: []Policy{{Method: "*", MaxAttempts: 2, Idempotent: true, HedgingDelay: 10 * time.Millisecond}}})
+	interceptor := r.UnaryClientInterceptor("hello_service")
+
+	attempts := make(chan int, 2)
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts <- 1
+		if len(attempts) == 1 {
+			// the first attempt hangs until it is canceled
+			<-ctx.Done()
+			return grpc.Errorf(codes.Canceled, "canceled")
+		}
+		reply.(*pb.HealthCheckResponse).Status = pb.HealthCheckResponse_SERVING
+		return nil
+	}
+
+	reply := &pb.HealthCheckResponse{}
+	err := interceptor(context.Background(), "/grpc.health.v1.Health/Check", nil, reply, nil, invoker)
+	if err != nil || reply.Status != pb.HealthCheckResponse_SERVING {
+		t.Errorf("hedge error, reply=%v, err=%v", reply, err)
+	}
+}
+
+func TestBudgetFatalCode(t *testing.T) {
+	r := New(Options{Policies: []Policy{{Method: "*", MaxAttempts: 3, InitialBackoff: time.Millisecond}}, MaxTokens: 4, TokenRatio: 1})
+	interceptor := r.UnaryClientInterceptor("hello_service")
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return grpc.Errorf(codes.NotFound, "no such user")
+	}
+	for i := 0; i < 3; i++ {
+		interceptor(context.Background(), "/pb.HelloService/NormalHello", nil, nil, nil, invoker)
+	}
+
+	// the calls failed by the caller do not throttle the retries
+	if b := r.budget("hello_service"); !b.Allow() || b.tokens != 4 {
+		t.Errorf("budget error, get=%v", b.tokens)
+	}
+}
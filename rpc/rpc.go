@@ -1,17 +1,195 @@
-// grpc interceptor chain builder & middlewares. Now, we are only using unary rpc,
-// this package only support unary interceptor.
+// grpc interceptor chain builder & middlewares. Unary interceptors are chained
+// by UnaryInterceptorChain, stream interceptors by StreamInterceptorChain.
 
 package rpc
 
 import (
+	"reflect"
+	"regexp"
+	"runtime"
+	"sync"
+
+	"gomicro/rpc/errors"
+	"gomicro/rpc/health"
+	"gomicro/rpc/metrics"
+
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 )
 
+// ServerOption 定制NewServer创建的grpc服务
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	unary       []grpc.UnaryServerInterceptor
+	unaryNames  []string
+	stream      []grpc.StreamServerInterceptor
+	streamNames []string
+	grpcOpts    []grpc.ServerOption
+	register    []func(*grpc.Server) // services registered along with the server
+	metrics     *metrics.Metrics
+	errors      bool
+}
+
+// WithUnaryInterceptors appends unary interceptors after the default Recovery & Logging chain
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(o *serverOptions) {
+		for _, interceptor := range interceptors {
+			o.unary = append(o.unary, interceptor)
+			o.unaryNames = append(o.unaryNames, funcName(interceptor))
+		}
+	}
+}
+
+// WithNamedUnaryInterceptor appends a unary interceptor like WithUnaryInterceptors,
+// name is shown by Info in place of the function name, e.g. "ratelimit"
+func WithNamedUnaryInterceptor(name string, interceptor grpc.UnaryServerInterceptor) ServerOption {
+	return func(o *serverOptions) {
+		o.unary = append(o.unary, interceptor)
+		o.unaryNames = append(o.unaryNames, name)
+	}
+}
+
+// WithStreamInterceptors appends stream interceptors after the default chain
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return func(o *serverOptions) {
+		for _, interceptor := range interceptors {
+			o.stream = append(o.stream, interceptor)
+			o.streamNames = append(o.streamNames, funcName(interceptor))
+		}
+	}
+}
+
+// WithNamedStreamInterceptor appends a stream interceptor like WithStreamInterceptors,
+// name is shown by Info in place of the function name
+func WithNamedStreamInterceptor(name string, interceptor grpc.StreamServerInterceptor) ServerOption {
+	return func(o *serverOptions) {
+		o.stream = append(o.stream, interceptor)
+		o.streamNames = append(o.streamNames, name)
+	}
+}
+
+// WithGRPCOptions passes raw grpc.ServerOption to grpc.NewServer
+func WithGRPCOptions(opts ...grpc.ServerOption) ServerOption {
+	return func(o *serverOptions) {
+		o.grpcOpts = append(o.grpcOpts, opts...)
+	}
+}
+
+// WithHealth registers the grpc.health.v1.Health service backed by h,
+// h.Start should be called to run its checkers.
+func WithHealth(h *health.Server) ServerOption {
+	return func(o *serverOptions) {
+		o.register = append(o.register, h.Register)
+	}
+}
+
+// WithMetrics records the calls by m along with the default grpc_prometheus,
+// e.g. with latency buckets, extra labels or a registry of a test
+func WithMetrics(m *metrics.Metrics) ServerOption {
+	return func(o *serverOptions) {
+		o.metrics = m
+	}
+}
+
+// WithErrors converts the errors of the handlers to status by the rpc/errors interceptors,
+// installed inside Logging & the metrics so that they see the converted codes,
+// and outside the interceptors of WithUnaryInterceptors & WithStreamInterceptors
+func WithErrors() ServerOption {
+	return func(o *serverOptions) {
+		o.errors = true
+	}
+}
+
+// WithReflection registers the grpc server reflection service, for tools like cmd/gomicro-call
+// to list the services and call them without the proto files
+func WithReflection() ServerOption {
+	return func(o *serverOptions) {
+		o.register = append(o.register, func(s *grpc.Server) { reflection.Register(s) })
+	}
+}
+
 // NewServer 创建grpc服务
-func NewServer() *grpc.Server {
-	return grpc.NewServer(
-		grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptor),
-		grpc.UnaryInterceptor(UnaryInterceptorChain(Recovery, Logging, grpc_prometheus.UnaryServerInterceptor)),
-	)
+func NewServer(opts ...ServerOption) *grpc.Server {
+	o := &serverOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	defaults := &serverOptions{}
+	WithUnaryInterceptors(Recovery, Logging, grpc_prometheus.UnaryServerInterceptor)(defaults)
+	WithStreamInterceptors(StreamRecovery, grpc_prometheus.StreamServerInterceptor)(defaults)
+	if o.metrics != nil {
+		WithUnaryInterceptors(o.metrics.UnaryServerInterceptor())(defaults)
+		WithStreamInterceptors(o.metrics.StreamServerInterceptor())(defaults)
+	}
+	if o.errors {
+		WithUnaryInterceptors(errors.UnaryServerInterceptor)(defaults)
+		WithStreamInterceptors(errors.StreamServerInterceptor)(defaults)
+	}
+	unary := append(defaults.unary, o.unary...)
+	stream := append(defaults.stream, o.stream...)
+
+	grpcOpts := append([]grpc.ServerOption{
+		grpc.StreamInterceptor(StreamInterceptorChain(stream...)),
+		grpc.UnaryInterceptor(UnaryInterceptorChain(unary...)),
+	}, o.grpcOpts...)
+
+	s := grpc.NewServer(grpcOpts...)
+	for _, register := range o.register {
+		register(s)
+	}
+
+	info := &ServerInfo{
+		UnaryInterceptors:  append(defaults.unaryNames, o.unaryNames...),
+		StreamInterceptors: append(defaults.streamNames, o.streamNames...),
+	}
+	serversLock.Lock()
+	servers[s] = info
+	serversLock.Unlock()
+
+	return s
+}
+
+// ServerInfo describes a server created by NewServer
+type ServerInfo struct {
+	UnaryInterceptors  []string `json:"unary_interceptors"`
+	StreamInterceptors []string `json:"stream_interceptors"`
+}
+
+var (
+	serversLock sync.RWMutex
+	servers     = make(map[*grpc.Server]*ServerInfo)
+)
+
+// Info returns the interceptors of the server created by NewServer, till it is released
+func Info(s *grpc.Server) (ServerInfo, bool) {
+	serversLock.RLock()
+	defer serversLock.RUnlock()
+
+	if info, ok := servers[s]; ok {
+		return *info, true
+	}
+	return ServerInfo{}, false
+}
+
+// Release forgets the server created by NewServer, Run releases the server it stopped,
+// the servers stopped without Run should be released after Stop or GracefulStop
+func Release(s *grpc.Server) {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+	delete(servers, s)
+}
+
+// closureSuffix is the suffix of the closures returned by a function, e.g. ".func1"
+var closureSuffix = regexp.MustCompile(`\.func\d+(\.\d+)*$`)
+
+// funcName returns the name of the interceptor, e.g. "gomicro/rpc.Recovery", the closures are named
+// by the function returning them, e.g. "gomicro/rpc/ratelimit.(*Limiter).UnaryServerInterceptor"
+func funcName(f interface{}) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+		return closureSuffix.ReplaceAllString(fn.Name(), "")
+	}
+	return "unknown"
 }
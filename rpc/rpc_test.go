@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+
+	"gomicro/rpc/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestWithMetrics(t *testing.T) {
+	m, err := metrics.New(metrics.Options{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("new metrics error: %v", err)
+	}
+	s := NewServer(WithMetrics(m))
+	defer Release(s)
+
+	info, ok := Info(s)
+	if !ok || len(info.UnaryInterceptors) != 4 || len(info.StreamInterceptors) != 3 {
+		t.Fatalf("info error, get=%+v", info)
+	}
+	if name := info.UnaryInterceptors[2]; !strings.Contains(name, "go-grpc-prometheus.UnaryServerInterceptor") {
+		t.Errorf("grpc_prometheus error, get=%s", name)
+	}
+	if name := info.StreamInterceptors[1]; !strings.Contains(name, "go-grpc-prometheus.StreamServerInterceptor") {
+		t.Errorf("grpc_prometheus error, get=%s", name)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	noop := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(ctx, req)
+	}
+	s := NewServer(WithUnaryInterceptors(noop), WithNamedUnaryInterceptor("noop", noop))
+
+	info, ok := Info(s)
+	if !ok || len(info.UnaryInterceptors) != 5 {
+		t.Fatalf("info error, get=%+v", info)
+	}
+	if name := info.UnaryInterceptors[3]; name != "gomicro/rpc.TestInfo" {
+		t.Errorf("closure name error, get=%s", name)
+	}
+	if name := info.UnaryInterceptors[4]; name != "noop" {
+		t.Errorf("explicit name error, get=%s", name)
+	}
+
+	// the server is forgotten once released
+	Release(s)
+	if _, ok := Info(s); ok {
+		t.Errorf("release error, get=%v", ok)
+	}
+}
+
+func TestWithErrors(t *testing.T) {
+	s := NewServer(WithErrors(), WithUnaryInterceptors(Recovery))
+	defer Release(s)
+
+	// inside Logging & grpc_prometheus, outside the appended interceptors
+	info, _ := Info(s)
+	if len(info.UnaryInterceptors) != 5 || info.UnaryInterceptors[3] != "gomicro/rpc/errors.UnaryServerInterceptor" {
+		t.Errorf("unary error, get=%v", info.UnaryInterceptors)
+	}
+	if len(info.StreamInterceptors) != 3 || info.StreamInterceptors[2] != "gomicro/rpc/errors.StreamServerInterceptor" {
+		t.Errorf("stream error, get=%v", info.StreamInterceptors)
+	}
+}
@@ -0,0 +1,171 @@
+package rpctest
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"gomicro/log"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Logs is the output of the log package captured by CaptureLogs
+type Logs struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (l *Logs) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.buf.Write(p)
+}
+
+// String returns the captured logs
+func (l *Logs) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.buf.String()
+}
+
+// Contains reports whether the captured logs contain s
+func (l *Logs) Contains(s string) bool {
+	return strings.Contains(l.String(), s)
+}
+
+// AssertContains fails t if the captured logs don't contain s
+func (l *Logs) AssertContains(t testing.TB, s string) {
+	t.Helper()
+	if !l.Contains(s) {
+		t.Errorf("logs error, want %q in:\n%s", s, l.String())
+	}
+}
+
+// CaptureLogs captures the output of the log package at debug level, till t.Cleanup
+func CaptureLogs(t testing.TB) *Logs {
+	logs := &Logs{}
+	out, level := log.Writer(), log.GetOutputLevel()
+	log.SetOutput(logs)
+	log.SetOutputLevel(log.Ldebug)
+
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		log.SetOutputLevel(level)
+	})
+	return logs
+}
+
+// Metrics is a snapshot of the prometheus metrics to get the deltas after
+type Metrics struct {
+	gatherer prometheus.Gatherer
+	before   []*dto.MetricFamily
+}
+
+// SnapshotMetrics snapshots the metrics of prometheus.DefaultGatherer
+func SnapshotMetrics(t testing.TB) *Metrics {
+	return SnapshotGatherer(t, prometheus.DefaultGatherer)
+}
+
+// SnapshotGatherer snapshots the metrics of gatherer
+func SnapshotGatherer(t testing.TB, gatherer prometheus.Gatherer) *Metrics {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("rpctest: gather metrics error: %v", err)
+	}
+	return &Metrics{gatherer: gatherer, before: families}
+}
+
+// Delta returns the change of the metric since the snapshot, summed over the series
+// having the label pairs, e.g. Delta(t, "grpc_server_panics_total", "grpc_method", "PanicHello").
+// It is the sample count of histograms & summaries.
+func (m *Metrics) Delta(t testing.TB, name string, labels ...string) float64 {
+	t.Helper()
+	after, err := m.gatherer.Gather()
+	if err != nil {
+		t.Fatalf("rpctest: gather metrics error: %v", err)
+	}
+	return sum(after, name, labels) - sum(m.before, name, labels)
+}
+
+// AssertDelta fails t if the change of the metric is not want
+func (m *Metrics) AssertDelta(t testing.TB, want float64, name string, labels ...string) {
+	t.Helper()
+	if got := m.Delta(t, name, labels...); got != want {
+		t.Errorf("metric %s%v delta error, get=%v, want=%v", name, labels, got, want)
+	}
+}
+
+func sum(families []*dto.MetricFamily, name string, labels []string) float64 {
+	total := 0.0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.Metric {
+			if hasLabels(metric, labels) {
+				total += value(metric)
+			}
+		}
+	}
+	return total
+}
+
+func hasLabels(metric *dto.Metric, labels []string) bool {
+	for i := 0; i+1 < len(labels); i += 2 {
+		found := false
+		for _, pair := range metric.Label {
+			if pair.GetName() == labels[i] && pair.GetValue() == labels[i+1] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func value(metric *dto.Metric) float64 {
+	switch {
+	case metric.Counter != nil:
+		return metric.Counter.GetValue()
+	case metric.Gauge != nil:
+		return metric.Gauge.GetValue()
+	case metric.Histogram != nil:
+		return float64(metric.Histogram.GetSampleCount())
+	case metric.Summary != nil:
+		return float64(metric.Summary.GetSampleCount())
+	case metric.Untyped != nil:
+		return metric.Untyped.GetValue()
+	}
+	return 0
+}
+
+// AssertCode fails t if the code of err is not want
+func AssertCode(t testing.TB, err error, want codes.Code) {
+	t.Helper()
+	if got := grpc.Code(err); got != want {
+		t.Errorf("status code error, get=%v (%v), want=%v", got, err, want)
+	}
+}
+
+// AssertDetail fails t if the status of err has no detail equal to want
+func AssertDetail(t testing.TB, err error, want proto.Message) {
+	t.Helper()
+	st, _ := status.FromError(err)
+	for _, detail := range st.Details() {
+		if pb, ok := detail.(proto.Message); ok && proto.Equal(pb, want) {
+			return
+		}
+	}
+	t.Errorf("status detail error, get=%v, want=%v", st.Details(), want)
+}
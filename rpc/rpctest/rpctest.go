@@ -0,0 +1,68 @@
+// Package rpctest runs grpc servers in memory for tests. The servers listen on
+// bufconn with the interceptor chain of rpc.NewServer, and are stopped by t.Cleanup.
+// There are helpers to assert the captured logs, the deltas of the prometheus
+// metrics and the status of the calls.
+//
+//	conn := rpctest.NewServer(t, func(s *grpc.Server) {
+//		pb.RegisterHelloServiceServer(s, &HelloServer{})
+//	})
+//	_, err := pb.NewHelloServiceClient(conn).ErrorHello(ctx, &pb.HelloRequest{})
+//	rpctest.AssertCode(t, err, codes.Canceled)
+package rpctest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"gomicro/rpc"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// DialTimeout waits the connection to be ready
+var DialTimeout = 5 * time.Second
+
+// NewServer starts the server created by rpc.NewServer(opts...) with the services
+// registered by register, and returns a ready connection to it
+func NewServer(t testing.TB, register func(*grpc.Server), opts ...rpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+
+	s := rpc.NewServer(opts...)
+	if register != nil {
+		register(s)
+	}
+	return Start(t, s)
+}
+
+// Start serves s on bufconn, and returns a ready connection to it dialed with opts,
+// e.g. grpc.WithUnaryInterceptor for client interceptors. s is stopped & released by t.Cleanup.
+func Start(t testing.TB, s *grpc.Server, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	go s.Serve(listener)
+	t.Cleanup(func() {
+		s.Stop()
+		rpc.Release(s)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), DialTimeout)
+	defer cancel()
+
+	opts = append([]grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+			return listener.Dial()
+		}),
+	}, opts...)
+	conn, err := grpc.DialContext(ctx, "bufconn", opts...)
+	if err != nil {
+		t.Fatalf("rpctest: dial error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
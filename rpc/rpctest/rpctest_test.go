@@ -0,0 +1,36 @@
+package rpctest
+
+import (
+	"testing"
+
+	"gomicro/rpc"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestNewServer(t *testing.T) {
+	logs := CaptureLogs(t)
+	metrics := SnapshotMetrics(t)
+
+	conn := NewServer(t, func(s *grpc.Server) {
+		pb.RegisterHealthServer(s, health.NewServer())
+	}, rpc.WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if req.(*pb.HealthCheckRequest).Service == "panic" {
+			panic("boom")
+		}
+		return handler(ctx, req)
+	}))
+	client := pb.NewHealthClient(conn)
+
+	_, err := client.Check(context.Background(), &pb.HealthCheckRequest{})
+	AssertCode(t, err, codes.OK)
+
+	_, err = client.Check(context.Background(), &pb.HealthCheckRequest{Service: "panic"})
+	AssertCode(t, err, codes.Internal)
+	logs.AssertContains(t, "panic grpc invoke: /grpc.health.v1.Health/Check")
+	metrics.AssertDelta(t, 1, "grpc_server_panics_total", "grpc_method", "Check")
+}
@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gomicro/log"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// DefaultStopTimeout is the time allowed for the pre-stop hooks & GracefulStop
+const DefaultStopTimeout = 10 * time.Second
+
+// RunOptions of Run
+type RunOptions struct {
+	// Signals stopping the server, SIGINT & SIGTERM if empty
+	Signals []os.Signal
+	// PreStop hooks run before draining, e.g. deregister from naming, set health to NOT_SERVING
+	PreStop []func(ctx context.Context) error
+	// DrainDelay waits after the pre-stop hooks, so the clients can take the instance out
+	DrainDelay time.Duration
+	// PostStop hooks run after the server stopped, e.g. shut the admin server down
+	PostStop []func(ctx context.Context) error
+	// StopTimeout bounds the pre-stop hooks and GracefulStop, then Stop is called, DefaultStopTimeout if zero
+	StopTimeout time.Duration
+}
+
+// SignalError is returned by Run when the server is stopped by a signal
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return "rpc: received signal " + e.Signal.String()
+}
+
+// Run serves until a signal or the cancellation of ctx, then shuts the server down:
+// runs the pre-stop hooks, waits the drain delay and stops gracefully, falling back
+// to Stop after the timeout. It returns why the server stopped, the error of Serve,
+// a *SignalError or ctx.Err(), and never exits the process. The server stopped is released, see Release.
+func Run(ctx context.Context, server *grpc.Server, listener net.Listener, opts RunOptions) error {
+	signals := opts.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	timeout := opts.StopTimeout
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	var reason error
+	select {
+	case err := <-served:
+		// serving failed, nothing to drain
+		return err
+	case sig := <-received:
+		reason = &SignalError{Signal: sig}
+	case <-ctx.Done():
+		reason = ctx.Err()
+	}
+	log.Printf("rpc: shutting down server: %v", reason)
+
+	hookCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, hook := range opts.PreStop {
+		if err := hook(hookCtx); err != nil {
+			log.Warnf("rpc: pre-stop hook error: %v", err)
+		}
+	}
+
+	// a second signal skips draining
+	if opts.DrainDelay > 0 {
+		log.Printf("rpc: draining for %v", opts.DrainDelay)
+		timer := time.NewTimer(opts.DrainDelay)
+		select {
+		case <-timer.C:
+		case sig := <-received:
+			timer.Stop()
+			log.Warnf("rpc: received signal %v while draining, stop now", sig)
+		}
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		log.Warnf("rpc: graceful stop timeout after %v, stop now", timeout)
+		server.Stop()
+		<-stopped
+	case sig := <-received:
+		log.Warnf("rpc: received signal %v while stopping, stop now", sig)
+		server.Stop()
+		<-stopped
+	}
+
+	Release(server)
+	log.Printf("rpc: server stopped")
+
+	postCtx, postCancel := context.WithTimeout(context.Background(), timeout)
+	defer postCancel()
+	for _, hook := range opts.PostStop {
+		if err := hook(postCtx); err != nil {
+			log.Warnf("rpc: post-stop hook error: %v", err)
+		}
+	}
+	return reason
+}
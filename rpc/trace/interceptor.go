@@ -0,0 +1,237 @@
+package trace
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	"gomicro/rpc/internal/match"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// metadata keys of W3C trace context
+const (
+	TraceParentKey = "traceparent"
+	TraceStateKey  = "tracestate"
+)
+
+// Extract returns ctx with the remote span context in the incoming metadata
+func Extract(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[TraceParentKey]) == 0 {
+		return ctx
+	}
+
+	sc, err := ParseTraceParent(md[TraceParentKey][0])
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = strings.Join(md[TraceStateKey], ",")
+	return ContextWithRemote(ctx, sc)
+}
+
+// Inject returns ctx with the span context of ctx in the outgoing metadata
+func Inject(ctx context.Context) context.Context {
+	sc := SpanContextFromContext(ctx)
+	if !sc.Valid() {
+		return ctx
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(TraceParentKey, sc.TraceParent())
+	if sc.TraceState != "" {
+		md.Set(TraceStateKey, sc.TraceState)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+func start(ctx context.Context, tracer Tracer, fullMethod string, kind Kind) (context.Context, Span) {
+	ctx, span := tracer.Start(ctx, strings.TrimPrefix(fullMethod, "/"), kind)
+
+	service, method := match.Split(fullMethod)
+	span.SetAttribute("rpc.system", "grpc")
+	span.SetAttribute("rpc.service", service)
+	span.SetAttribute("rpc.method", method)
+	if p, ok := peer.FromContext(ctx); ok {
+		span.SetAttribute("net.peer.address", p.Addr.String())
+	}
+	return ctx, span
+}
+
+func finish(span Span, err error) {
+	st, _ := status.FromError(err)
+	span.SetAttribute("rpc.grpc.status_code", int(st.Code()))
+	span.SetStatus(st.Code(), st.Message())
+	span.End()
+}
+
+func size(msg interface{}) int {
+	if pb, ok := msg.(proto.Message); ok {
+		return proto.Size(pb)
+	}
+	return 0
+}
+
+// UnaryServerInterceptor starts a server span per call, child of the caller's span
+func UnaryServerInterceptor(tracer Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := start(Extract(ctx), tracer, info.FullMethod, KindServer)
+		span.SetAttribute("rpc.request.size", size(request))
+
+		response, err := handler(ctx, request)
+		if err == nil {
+			span.SetAttribute("rpc.response.size", size(response))
+		}
+		finish(span, err)
+		return response, err
+	}
+}
+
+// StreamServerInterceptor starts a server span per stream, child of the caller's span
+func StreamServerInterceptor(tracer Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := start(Extract(stream.Context()), tracer, info.FullMethod, KindServer)
+		s := &serverStream{ServerStream: stream, ctx: ctx}
+
+		err := handler(srv, s)
+		s.counter.record(span)
+		finish(span, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor starts a client span per call, and propagates it to the server
+func UnaryClientInterceptor(tracer Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := start(ctx, tracer, method, KindClient)
+		span.SetAttribute("net.peer.name", cc.Target())
+		span.SetAttribute("rpc.request.size", size(req))
+
+		err := invoker(Inject(ctx), method, req, reply, cc, opts...)
+		if err == nil {
+			span.SetAttribute("rpc.response.size", size(reply))
+		}
+		finish(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor starts a client span per stream, and propagates it to the server.
+// The span ends when the stream is done, i.e. RecvMsg returns an error or the only response.
+func StreamClientInterceptor(tracer Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := start(ctx, tracer, method, KindClient)
+		span.SetAttribute("net.peer.name", cc.Target())
+
+		stream, err := streamer(Inject(ctx), desc, cc, method, opts...)
+		if err != nil {
+			finish(span, err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: stream, desc: desc, span: span}, nil
+	}
+}
+
+// counter of the stream messages
+type counter struct {
+	lock                   sync.Mutex
+	sent, received         int
+	sentBytes, recvedBytes int
+}
+
+func (c *counter) send(m interface{}) {
+	c.lock.Lock()
+	c.sent++
+	c.sentBytes += size(m)
+	c.lock.Unlock()
+}
+
+func (c *counter) recv(m interface{}) {
+	c.lock.Lock()
+	c.received++
+	c.recvedBytes += size(m)
+	c.lock.Unlock()
+}
+
+func (c *counter) record(span Span) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	span.SetAttribute("rpc.sent.messages", c.sent)
+	span.SetAttribute("rpc.sent.size", c.sentBytes)
+	span.SetAttribute("rpc.received.messages", c.received)
+	span.SetAttribute("rpc.received.size", c.recvedBytes)
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	counter counter
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.counter.send(m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.counter.recv(m)
+	}
+	return err
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	desc    *grpc.StreamDesc
+	span    Span
+	counter counter
+	once    sync.Once
+}
+
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		s.counter.record(s.span)
+		finish(s.span, err)
+	})
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.counter.send(m)
+	} else if err != io.EOF {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	default:
+		s.counter.recv(m)
+		if !s.desc.ServerStreams {
+			s.end(nil)
+		}
+	}
+	return err
+}
@@ -0,0 +1,170 @@
+// Package trace is the tracing of grpc calls. The interceptors start a span per call,
+// propagate the W3C trace context (traceparent/tracestate) through the metadata, record
+// the status code & payload sizes, and append the trace ID to the request ID of the log.
+//
+// Spans are created by a Tracer: the builtin one exports them to memory or a JSON-lines
+// file, an OpenTelemetry SDK can be plugged in by an adapter whose spans return the W3C
+// ids in SpanContext, taking the parent from SpanContextFromContext.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"gomicro/log"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+// Kind of span
+type Kind int
+
+const (
+	// KindInternal is a span inside a process
+	KindInternal Kind = iota
+	// KindServer is a span of a handled call
+	KindServer
+	// KindClient is a span of an outgoing call
+	KindClient
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+// TraceID of W3C trace context
+type TraceID [16]byte
+
+// SpanID of W3C trace context
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// SpanContext is the propagated part of a span
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+	Remote     bool
+}
+
+// Valid reports whether both ids are non-zero
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// TraceParent formats sc to the W3C traceparent header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceParent parses the W3C traceparent header
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("trace: invalid traceparent %q", s)
+	}
+
+	var flags [1]byte
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return sc, fmt.Errorf("trace: invalid trace id %q", parts[1])
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return sc, fmt.Errorf("trace: invalid span id %q", parts[2])
+	}
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return sc, fmt.Errorf("trace: invalid flags %q", parts[3])
+	}
+	if !sc.Valid() {
+		return sc, fmt.Errorf("trace: zero id in traceparent %q", s)
+	}
+
+	sc.Sampled = flags[0]&1 == 1
+	sc.Remote = true
+	return sc, nil
+}
+
+// decodeHex decodes the lowercase hex string s to dst
+func decodeHex(dst []byte, s string) error {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return fmt.Errorf("invalid hex")
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+func randomID(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+}
+
+// Span of a call
+type Span interface {
+	SpanContext() SpanContext
+	SetAttribute(key string, value interface{})
+	SetStatus(code codes.Code, message string)
+	End()
+}
+
+// Tracer starts spans, the parent is the span or remote span context in ctx
+type Tracer interface {
+	Start(ctx context.Context, name string, kind Kind) (context.Context, Span)
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// NewContext returns a context with the span
+func NewContext(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// FromContext returns the span in ctx, nil if none
+func FromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
+
+// ContextWithRemote returns a context with the span context received from the caller
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the span in ctx, or the remote one
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span := FromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+func init() {
+	log.RegisterIDFunc("trace", func(ctx context.Context) string {
+		if sc := SpanContextFromContext(ctx); sc.Valid() {
+			return sc.TraceID.String()
+		}
+		return ""
+	})
+}
@@ -0,0 +1,62 @@
+package trace
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTraceParent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceParent(header)
+	if err != nil || !sc.Sampled || sc.TraceParent() != header {
+		t.Errorf("parse error, get=%v %v", sc.TraceParent(), err)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+	} {
+		if _, err := ParseTraceParent(invalid); err == nil {
+			t.Errorf("parse %q error, get=nil", invalid)
+		}
+	}
+}
+
+func TestServerInterceptor(t *testing.T) {
+	exporter := NewMemoryExporter()
+	interceptor := UnaryServerInterceptor(NewTracer(exporter))
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+
+	md := metadata.Pairs(TraceParentKey, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	var out metadata.MD
+	interceptor(ctx, &pb.HealthCheckRequest{Service: "hello"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		// the outgoing calls of the handler are children of the server span
+		out, _ = metadata.FromOutgoingContext(Inject(ctx))
+		return nil, grpc.Errorf(codes.NotFound, "unknown service")
+	})
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("export error, get=%d spans", len(spans))
+	}
+	span := spans[0]
+	if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("parent error, get=%s/%s", span.TraceID, span.ParentSpanID)
+	}
+	if span.Code != codes.NotFound.String() || span.Attributes["rpc.request.size"] != 7 {
+		t.Errorf("attributes error, get=%s %v", span.Code, span.Attributes)
+	}
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanID + "-01"
+	if got := out[TraceParentKey]; len(got) != 1 || got[0] != want {
+		t.Errorf("inject error, get=%v, want=%s", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+package trace
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"gomicro/log"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+// SpanData is an ended span of the builtin tracer
+type SpanData struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Code         string                 `json:"code"`
+	Message      string                 `json:"message,omitempty"`
+}
+
+// Exporter receives the ended spans of the builtin tracer
+type Exporter interface {
+	Export(span *SpanData)
+}
+
+// NewTracer returns the builtin tracer, the root spans are all sampled,
+// the others follow their parent
+func NewTracer(exporter Exporter) Tracer {
+	return &tracer{exporter: exporter}
+}
+
+type tracer struct {
+	exporter Exporter
+}
+
+func (t *tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	s := &span{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			Kind:       kind.String(),
+			Start:      time.Now(),
+			Attributes: make(map[string]interface{}),
+			Code:       codes.OK.String(),
+		},
+	}
+
+	if parent.Valid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.sc.TraceState = parent.TraceState
+		s.data.ParentSpanID = parent.SpanID.String()
+	} else {
+		randomID(s.sc.TraceID[:])
+		s.sc.Sampled = true
+	}
+	randomID(s.sc.SpanID[:])
+	s.data.TraceID = s.sc.TraceID.String()
+	s.data.SpanID = s.sc.SpanID.String()
+
+	return NewContext(ctx, s), s
+}
+
+type span struct {
+	tracer *tracer
+	sc     SpanContext
+
+	lock  sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (s *span) SetAttribute(key string, value interface{}) {
+	s.lock.Lock()
+	s.data.Attributes[key] = value
+	s.lock.Unlock()
+}
+
+func (s *span) SetStatus(code codes.Code, message string) {
+	s.lock.Lock()
+	s.data.Code = code.String()
+	s.data.Message = message
+	s.lock.Unlock()
+}
+
+func (s *span) End() {
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.lock.Unlock()
+
+	if s.sc.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.Export(&data)
+	}
+}
+
+// MemoryExporter keeps the spans in memory, for tests
+type MemoryExporter struct {
+	lock  sync.Mutex
+	spans []*SpanData
+}
+
+// NewMemoryExporter returns an empty MemoryExporter
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// Export appends the span
+func (e *MemoryExporter) Export(span *SpanData) {
+	e.lock.Lock()
+	e.spans = append(e.spans, span)
+	e.lock.Unlock()
+}
+
+// Spans returns the exported spans in the order of ending
+func (e *MemoryExporter) Spans() []*SpanData {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]*SpanData(nil), e.spans...)
+}
+
+// Reset drops the exported spans
+func (e *MemoryExporter) Reset() {
+	e.lock.Lock()
+	e.spans = nil
+	e.lock.Unlock()
+}
+
+// FileExporter writes the spans to a file, one JSON per line
+type FileExporter struct {
+	lock    sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewFileExporter opens the file to append spans
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Export writes the span
+func (e *FileExporter) Export(span *SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if err := e.encoder.Encode(span); err != nil {
+		log.Errorf("trace: export span %s error: %v", span.SpanID, err)
+	}
+}
+
+// Close closes the file
+func (e *FileExporter) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.file.Close()
+}
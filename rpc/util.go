@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"unicode/utf8"
 
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
@@ -31,3 +32,15 @@ func marshal(x interface{}) string {
 
 	return buf.String()
 }
+
+// truncate s to max bytes at a character boundary, no limit if max <= 0
+func truncate(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	end := max
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return fmt.Sprintf("%s...(truncated, %d bytes)", s[:end], len(s))
+}
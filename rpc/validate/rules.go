@@ -0,0 +1,189 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// Rule checks one field of a message, the zero value of a constraint disables it
+type Rule struct {
+	// Field is the proto field name, e.g. "greeting"
+	Field string
+	// Required fields must not be zero: empty string, 0, nil message or empty list
+	Required bool
+	// MinLen & MaxLen of a string (in characters) or of a repeated field
+	MinLen int
+	MaxLen int
+	// Pattern the string must match
+	Pattern string
+	// Min & Max of a number, see Number
+	Min *float64
+	Max *float64
+}
+
+// Number returns a pointer to v, for Rule.Min & Rule.Max
+func Number(v float64) *float64 {
+	return &v
+}
+
+// compiled rule of a message
+type fieldRule struct {
+	Rule
+	index   []int // index of the go struct field
+	pattern *regexp.Regexp
+}
+
+// Registry holds the rules of the messages without generated validators
+type Registry struct {
+	lock  sync.RWMutex
+	rules map[reflect.Type][]fieldRule
+}
+
+// NewRegistry return an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{rules: make(map[reflect.Type][]fieldRule)}
+}
+
+// DefaultRegistry is used by Register & the interceptor created with a nil registry
+var DefaultRegistry = NewRegistry()
+
+// Register adds the rules of the message to DefaultRegistry, it panics if a rule is invalid
+func Register(msg proto.Message, rules ...Rule) {
+	if err := DefaultRegistry.Register(msg, rules...); err != nil {
+		panic(err)
+	}
+}
+
+// Register adds the rules of the message
+func (r *Registry) Register(msg proto.Message, rules ...Rule) error {
+	t := reflect.TypeOf(msg)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %T is not a pointer to struct", msg)
+	}
+
+	compiled := make([]fieldRule, 0, len(rules))
+	for _, rule := range rules {
+		field, ok := fieldByProtoName(t.Elem(), rule.Field)
+		if !ok {
+			return fmt.Errorf("validate: %T has no field '%s'", msg, rule.Field)
+		}
+
+		fr := fieldRule{Rule: rule, index: field.Index}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("validate: invalid pattern of '%s': %v", rule.Field, err)
+			}
+			fr.pattern = pattern
+		}
+		compiled = append(compiled, fr)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.rules[t] = append(r.rules[t], compiled...)
+	return nil
+}
+
+// Check returns the violations of the registered rules, nil if the message is valid
+func (r *Registry) Check(msg interface{}) []*errdetails.BadRequest_FieldViolation {
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+
+	r.lock.RLock()
+	rules := r.rules[v.Type()]
+	r.lock.RUnlock()
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for i := range rules {
+		if desc := rules[i].check(v.Elem().FieldByIndex(rules[i].index)); desc != "" {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: rules[i].Field, Description: desc})
+		}
+	}
+	return violations
+}
+
+// check returns the description of the violation, "" if valid
+func (r *fieldRule) check(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		n := utf8.RuneCountInString(s)
+		switch {
+		case r.Required && s == "":
+			return "is required"
+		case s == "":
+			return ""
+		case r.MinLen > 0 && n < r.MinLen:
+			return fmt.Sprintf("must be at least %d characters", r.MinLen)
+		case r.MaxLen > 0 && n > r.MaxLen:
+			return fmt.Sprintf("must be at most %d characters", r.MaxLen)
+		case r.pattern != nil && !r.pattern.MatchString(s):
+			return fmt.Sprintf("must match %q", r.Pattern)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return r.checkNumber(float64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return r.checkNumber(float64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return r.checkNumber(v.Float())
+
+	case reflect.Slice, reflect.Map:
+		n := v.Len()
+		switch {
+		case r.Required && n == 0:
+			return "is required"
+		case r.MinLen > 0 && n < r.MinLen:
+			return fmt.Sprintf("must have at least %d items", r.MinLen)
+		case r.MaxLen > 0 && n > r.MaxLen:
+			return fmt.Sprintf("must have at most %d items", r.MaxLen)
+		}
+
+	case reflect.Ptr, reflect.Interface:
+		if r.Required && v.IsNil() {
+			return "is required"
+		}
+
+	case reflect.Bool:
+		if r.Required && !v.Bool() {
+			return "is required"
+		}
+	}
+	return ""
+}
+
+func (r *fieldRule) checkNumber(f float64) string {
+	switch {
+	case r.Required && f == 0:
+		return "is required"
+	case r.Min != nil && f < *r.Min:
+		return fmt.Sprintf("must be greater than or equal to %g", *r.Min)
+	case r.Max != nil && f > *r.Max:
+		return fmt.Sprintf("must be less than or equal to %g", *r.Max)
+	}
+	return ""
+}
+
+// fieldByProtoName finds the struct field by the name in the protobuf tag, e.g. `protobuf:"bytes,1,opt,name=greeting"`
+func fieldByProtoName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		for _, part := range strings.Split(field.Tag.Get("protobuf"), ",") {
+			if part == "name="+name {
+				return field, true
+			}
+		}
+	}
+	// fall back to the go field name
+	return t.FieldByName(name)
+}
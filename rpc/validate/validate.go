@@ -0,0 +1,131 @@
+// Package validate is the request validation interceptor for grpc.
+// Requests having a `Validate() error` method (protoc-gen-validate style) are checked
+// by it, the others by the rules registered in a Registry. Invalid requests are
+// rejected with InvalidArgument and errdetails.BadRequest in the status details.
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"gomicro/log"
+
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validator is implemented by the protoc-gen-validate generated messages
+type validator interface {
+	Validate() error
+}
+
+// fieldError is implemented by the protoc-gen-validate generated <Message>ValidationError
+type fieldError interface {
+	Field() string
+	Reason() string
+}
+
+// multiError is implemented by the protoc-gen-validate generated <Message>MultiError
+type multiError interface {
+	AllErrors() []error
+}
+
+// causer is implemented by the protoc-gen-validate errors of embedded messages
+type causer interface {
+	Cause() error
+}
+
+// Validate checks the request, returns an InvalidArgument status error if it is invalid
+func Validate(registry *Registry, request interface{}) error {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	if v, ok := request.(validator); ok {
+		if err := v.Validate(); err != nil {
+			violations = append(violations, toViolations("", err)...)
+		}
+	}
+	violations = append(violations, registry.Check(request)...)
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return invalidArgument(violations)
+}
+
+// UnaryServerInterceptor validates the requests before the handler, registry is DefaultRegistry if nil
+func UnaryServerInterceptor(registry *Registry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := Validate(registry, request); err != nil {
+			log.CtxDebugf(ctx, "invalid request of %s: %v", info.FullMethod, err)
+			return nil, err
+		}
+		return handler(ctx, request)
+	}
+}
+
+// StreamServerInterceptor validates every message received from the client stream
+func StreamServerInterceptor(registry *Registry) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: stream, registry: registry})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+	registry *Registry
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return Validate(s.registry, m)
+}
+
+// toViolations converts the protoc-gen-validate errors to field violations
+func toViolations(prefix string, err error) []*errdetails.BadRequest_FieldViolation {
+	if multi, ok := err.(multiError); ok {
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, e := range multi.AllErrors() {
+			violations = append(violations, toViolations(prefix, e)...)
+		}
+		return violations
+	}
+
+	fe, ok := err.(fieldError)
+	if !ok {
+		return []*errdetails.BadRequest_FieldViolation{{Field: strings.TrimSuffix(prefix, "."), Description: err.Error()}}
+	}
+
+	field := prefix + fe.Field()
+	// the error of an embedded message tells which of its fields is invalid
+	if c, ok := err.(causer); ok && c.Cause() != nil {
+		if _, nested := c.Cause().(fieldError); nested {
+			return toViolations(field+".", c.Cause())
+		}
+		if _, nested := c.Cause().(multiError); nested {
+			return toViolations(field+".", c.Cause())
+		}
+	}
+	return []*errdetails.BadRequest_FieldViolation{{Field: field, Description: fe.Reason()}}
+}
+
+func invalidArgument(violations []*errdetails.BadRequest_FieldViolation) error {
+	fields := make([]string, len(violations))
+	for i, v := range violations {
+		fields[i] = fmt.Sprintf("%s %s", v.Field, v.Description)
+	}
+
+	st := status.New(codes.InvalidArgument, "invalid request: "+strings.Join(fields, "; "))
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.Register(&pb.HealthCheckRequest{}, Rule{Field: "service", Required: true, MaxLen: 8, Pattern: "^[a-z.]+$"})
+	if err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+
+	cases := map[string]string{
+		"":             "is required",
+		"hello":        "",
+		"hello_world!": "must be at most 8 characters",
+		"Hello":        `must match "^[a-z.]+$"`,
+	}
+	for service, want := range cases {
+		violations := registry.Check(&pb.HealthCheckRequest{Service: service})
+		got := ""
+		if len(violations) > 0 {
+			got = violations[0].Description
+		}
+		if got != want {
+			t.Errorf("check %q error, get=%q, want=%q", service, got, want)
+		}
+	}
+
+	if err := registry.Register(&pb.HealthCheckRequest{}, Rule{Field: "unknown"}); err == nil {
+		t.Errorf("unknown field should be rejected")
+	}
+}
+
+type fieldErr struct {
+	field, reason string
+	cause         error
+}
+
+func (e fieldErr) Error() string  { return e.field + ": " + e.reason }
+func (e fieldErr) Field() string  { return e.field }
+func (e fieldErr) Reason() string { return e.reason }
+func (e fieldErr) Cause() error   { return e.cause }
+
+type generated struct{ err error }
+
+func (g *generated) Validate() error { return g.err }
+
+func TestValidate(t *testing.T) {
+	nested := fieldErr{field: "user", reason: "embedded message failed validation", cause: fieldErr{field: "name", reason: "value length must be at least 1 runes"}}
+
+	err := Validate(NewRegistry(), &generated{err: nested})
+	st, _ := status.FromError(err)
+	if st.Code() != codes.InvalidArgument || len(st.Details()) != 1 {
+		t.Fatalf("invalid argument with details expected, get=%v", err)
+	}
+
+	violations := st.Details()[0].(*errdetails.BadRequest).FieldViolations
+	if len(violations) != 1 || violations[0].Field != "user.name" {
+		t.Errorf("nested violation error, get=%v", violations)
+	}
+
+	if err := Validate(NewRegistry(), &generated{err: errors.New("bad")}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("plain validate error should be invalid argument, get=%v", err)
+	}
+	if err := Validate(NewRegistry(), &generated{}); err != nil {
+		t.Errorf("valid request error: %v", err)
+	}
+}